@@ -68,6 +68,26 @@ var destroyDiskCmd = &cobra.Command{
 	},
 }
 
+var destroyFileCmd = &cobra.Command{
+	Use:   "file [id]",
+	Short: "Stop a running file experiment (optionally by id)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := ""
+		if len(args) == 1 {
+			id = args[0]
+		}
+		state, err := exec.KillTrackedExperiment("file", id)
+		if err != nil {
+			return err
+		}
+		if state != nil {
+			fmt.Printf("Stopped file experiment id=%s pid=%d.\n", state.ID, state.PID)
+		}
+		return nil
+	},
+}
+
 var destroyNetCmd = &cobra.Command{
 	Use:   "net [id]",
 	Short: "Stop a running network experiment (optionally by id)",
@@ -92,5 +112,6 @@ func init() {
 	destroyCmd.AddCommand(destroyCpuCmd)
 	destroyCmd.AddCommand(destroyMemCmd)
 	destroyCmd.AddCommand(destroyDiskCmd)
+	destroyCmd.AddCommand(destroyFileCmd)
 	destroyCmd.AddCommand(destroyNetCmd)
 }
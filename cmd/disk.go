@@ -6,7 +6,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"chaosblade-win/exec"
 	"chaosblade-win/spec"
@@ -16,51 +19,109 @@ import (
 )
 
 var diskSizeMB int64
-var diskPath string
+var diskPaths []string
+var diskVolumes string
 var diskPercent float64
+var diskFreeLeft string
+var diskUsedPercent float64
+var diskMethod string
+var diskContent string
+var diskRate string
 var diskDetach bool
 var diskDetachedChild bool
 
 var diskTargetSpec = spec.Registry["disk"]
 
+// diskSafetyMargin is the free space disk fill always leaves on the volume.
+const diskSafetyMargin = 64 << 20 // 64 MB
+
 var diskCmd = &cobra.Command{
 	Use:   "disk",
 	Short: diskTargetSpec.Short,
 }
 
+// diskFillTarget is one path filled by a disk fill experiment.
+type diskFillTarget struct {
+	path      string // file or directory handed to the runner ("" = temp file)
+	usagePath string // directory whose volume is measured
+	sizeBytes int64
+	freeLeft  int64 // when >0, fill until the volume has this much free space
+}
+
+func (t diskFillTarget) label() string {
+	if t.path == "" {
+		return "temporary file"
+	}
+	return t.path
+}
+
 var diskFillCmd = &cobra.Command{
 	Use:   "fill",
 	Short: spec.MustActionSpec("disk", "fill").Short,
 	Long:  spec.MustActionSpec("disk", "fill").Long,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		sizeBytes := diskSizeMB * 1024 * 1024
+		method := exec.DiskFillMethod(diskMethod)
+		switch method {
+		case exec.DiskFillWrite, exec.DiskFillFallocate, exec.DiskFillTruncate:
+		default:
+			return fmt.Errorf("unknown method %q (expected write, fallocate, or truncate)", diskMethod)
+		}
 
-		usagePath := diskPath
-		if usagePath == "" {
-			usagePath = os.TempDir()
-		} else {
-			usagePath = filepath.Dir(usagePath)
+		content := exec.DiskFillContent(diskContent)
+		switch content {
+		case exec.DiskFillZero, exec.DiskFillRandom, exec.DiskFillIncompressible:
+		default:
+			return fmt.Errorf("unknown content %q (expected zero, random, or incompressible)", diskContent)
+		}
+		if content != exec.DiskFillZero && method != exec.DiskFillWrite {
+			return fmt.Errorf("--content %s requires --method write", diskContent)
 		}
 
-		if diskPercent > 0 {
-			stats, err := disk.Usage(usagePath)
-			if err != nil {
-				return fmt.Errorf("query disk usage: %w", err)
-			}
-			sizeBytes = int64(float64(stats.Total) * diskPercent / 100)
+		rate, err := parseRate(diskRate)
+		if err != nil {
+			return err
+		}
+		if rate < 0 {
+			return fmt.Errorf("rate must not be negative")
+		}
+		if rate > 0 && method != exec.DiskFillWrite {
+			return fmt.Errorf("--rate requires --method write")
+		}
 
-			const safetyMargin = 64 << 20 // 64 MB
-			maxBytes := int64(0)
-			if stats.Free > safetyMargin {
-				maxBytes = int64(stats.Free - safetyMargin)
+		freeLeft, err := parseSize(diskFreeLeft)
+		if err != nil {
+			return err
+		}
+		if diskUsedPercent < 0 || diskUsedPercent > 100 {
+			return fmt.Errorf("used-percent must be between 0 and 100")
+		}
+		if freeLeft > 0 && diskUsedPercent > 0 {
+			return fmt.Errorf("use only one of --free-left and --used-percent")
+		}
+
+		specs := append([]string(nil), diskPaths...)
+		for _, v := range strings.Split(diskVolumes, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				specs = append(specs, volumeFillSpec(v))
 			}
-			if maxBytes > 0 && sizeBytes > maxBytes {
-				sizeBytes = maxBytes
+		}
+		if len(specs) == 0 {
+			specs = []string{""}
+		}
+		var targets []diskFillTarget
+		for _, s := range specs {
+			t, err := resolveFillTarget(s, freeLeft)
+			if err != nil {
+				return err
 			}
+			targets = append(targets, t)
 		}
 
 		if diskDetach && !diskDetachedChild {
-			args := []string{"create", "disk", "fill", "--size", fmt.Sprintf("%d", diskSizeMB), "--detached-child"}
+			args := []string{"create", "disk", "fill", "--size", fmt.Sprintf("%d", diskSizeMB), "--percent", strconv.FormatFloat(diskPercent, 'f', -1, 64), "--volumes", diskVolumes, "--free-left", diskFreeLeft, "--used-percent", strconv.FormatFloat(diskUsedPercent, 'f', -1, 64), "--method", diskMethod, "--content", diskContent, "--rate", diskRate, "--detached-child"}
+			for _, p := range diskPaths {
+				args = append(args, "--path", p)
+			}
 			pid, err := exec.StartDetachedExperiment(args)
 			if err != nil {
 				return err
@@ -69,29 +130,104 @@ var diskFillCmd = &cobra.Command{
 			return nil
 		}
 
-		id, cleanup, err := exec.TrackExperiment("disk", "fill", map[string]string{
-			"bytes":   fmt.Sprintf("%d", sizeBytes),
-			"path":    diskPath,
+		var paths, sizes, freeLefts []string
+		anyFreeLeft := false
+		for _, t := range targets {
+			paths = append(paths, t.path)
+			sizes = append(sizes, fmt.Sprintf("%d", t.sizeBytes))
+			freeLefts = append(freeLefts, fmt.Sprintf("%d", t.freeLeft))
+			anyFreeLeft = anyFreeLeft || t.freeLeft > 0
+		}
+		params := map[string]string{
+			"bytes":   strings.Join(sizes, ";"),
+			"path":    strings.Join(paths, ";"),
 			"percent": fmt.Sprintf("%.2f", diskPercent),
-		})
+			"method":  diskMethod,
+			"content": diskContent,
+		}
+		if rate > 0 {
+			params["rate"] = fmt.Sprintf("%d", rate)
+		}
+		if anyFreeLeft {
+			params["freeLeft"] = strings.Join(freeLefts, ";")
+		}
+
+		id, cleanup, err := exec.TrackExperiment("disk", "fill", params)
 		if err != nil {
 			return err
 		}
 		defer cleanup()
 		fmt.Printf("Started experiment id=%s\n", id)
 
-		runner := exec.NewDiskFillRunner(diskPath, sizeBytes)
+		runners := make([]*exec.DiskFillRunner, len(targets))
+		for i, t := range targets {
+			runner := exec.NewDiskFillRunner(t.path, t.sizeBytes)
+			if t.freeLeft > 0 {
+				runner = exec.NewDiskFillFreeLeftRunner(t.path, t.freeLeft)
+			}
+			runner.SetMethod(method)
+			runner.SetContent(content)
+			runner.SetRate(rate)
+			runner.TrackArtifacts("disk", id)
+			runners[i] = runner
+		}
 
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
-		target := diskPath
-		if target == "" {
-			target = "temporary file"
-		}
+		started := time.Now()
+		reportStatus(ctx, "disk", id, func() map[string]string {
+			elapsed := time.Since(started)
+			status := map[string]string{}
+			var total int64
+			for i, t := range targets {
+				// A single target keeps the plain keys; several are prefixed by path.
+				prefix := ""
+				if len(targets) > 1 {
+					prefix = t.label() + "."
+				}
+				written := runners[i].WrittenBytes()
+				total += written
+				status[prefix+"writtenBytes"] = fmt.Sprintf("%d", written)
+				goal := t.sizeBytes
+				if stats, err := disk.Usage(t.usagePath); err == nil {
+					status[prefix+"freeBytes"] = fmt.Sprintf("%d", stats.Free)
+					if t.freeLeft > 0 {
+						goal = written + max(int64(stats.Free)-t.freeLeft, 0)
+					}
+				}
+				if eta, ok := fillETA(written, goal, elapsed); ok {
+					status[prefix+"eta"] = eta.String()
+				}
+				if err := runners[i].FillWarning(); err != nil {
+					status[prefix+"fillWarning"] = err.Error()
+				}
+			}
+			status["writtenBytes"] = fmt.Sprintf("%d", total)
+			return status
+		})
 
-		fmt.Printf("Writing ~%.1f MB to %s. Press Ctrl+C to stop.\n", float64(sizeBytes)/1024.0/1024.0, target)
-		if err := runner.Run(ctx); err != nil && err != context.Canceled {
+		for i, t := range targets {
+			go warnUnaccountedFill(ctx, runners[i], t.label())
+			if t.freeLeft > 0 {
+				fmt.Printf("Filling %s until %.1f MB remain free, topping up if space is released.\n", t.label(), float64(t.freeLeft)/1024.0/1024.0)
+				continue
+			}
+			if rate > 0 {
+				fmt.Printf("Writing ~%.1f MB to %s at %.1f MB/s (about %s).\n", float64(t.sizeBytes)/1024.0/1024.0, t.label(), float64(rate)/1024.0/1024.0, time.Duration(float64(t.sizeBytes)/float64(rate)*float64(time.Second)).Round(time.Second))
+			} else {
+				fmt.Printf("Writing ~%.1f MB to %s using %s.\n", float64(t.sizeBytes)/1024.0/1024.0, t.label(), method)
+			}
+			if method == exec.DiskFillWrite {
+				label := ""
+				if len(targets) > 1 {
+					label = t.label()
+				}
+				go printFillProgress(ctx, runners[i], t.sizeBytes, label)
+			}
+		}
+		fmt.Println("Press Ctrl+C to stop.")
+		if err := exec.RunDiskFills(ctx, runners...); err != nil && err != context.Canceled {
 			return err
 		}
 		fmt.Println("Disk fill stopped and cleaned up.")
@@ -99,15 +235,170 @@ var diskFillCmd = &cobra.Command{
 	},
 }
 
+// volumeFillSpec turns a --volumes entry such as "D:" or "D:=20%" into a path spec
+// that fills a temporary file in the volume root.
+func volumeFillSpec(v string) string {
+	vol, override, found := strings.Cut(v, "=")
+	if len(vol) == 2 && vol[1] == ':' {
+		vol += string(filepath.Separator)
+	}
+	if found {
+		return vol + "=" + override
+	}
+	return vol
+}
+
+// isFillOverride reports whether v reads as a fill size ("512MB") or percent ("20%").
+// Out-of-range values still count so they are reported rather than taken as a path.
+func isFillOverride(v string) bool {
+	if strings.TrimSpace(v) == "" {
+		return false
+	}
+	if p, ok := strings.CutSuffix(v, "%"); ok {
+		_, err := strconv.ParseFloat(p, 64)
+		return err == nil
+	}
+	_, err := parseSize(v)
+	return err == nil
+}
+
+// resolveFillTarget parses a fill path spec, "PATH" or "PATH=SIZE" or "PATH=N%", and
+// sizes it from the per-path override or the global size, percent and free-space flags.
+// A suffix after the last '=' that is not a size or percent is part of the path.
+func resolveFillTarget(s string, freeLeft int64) (diskFillTarget, error) {
+	t := diskFillTarget{path: s}
+	override := ""
+	if i := strings.LastIndex(s, "="); i >= 0 && isFillOverride(s[i+1:]) {
+		t.path, override = s[:i], s[i+1:]
+	}
+
+	switch info, err := os.Stat(t.path); {
+	case t.path == "":
+		t.usagePath = os.TempDir()
+	case err == nil && info.IsDir():
+		t.usagePath = t.path
+	default:
+		t.usagePath = filepath.Dir(t.path)
+	}
+
+	percent := diskPercent
+	t.sizeBytes = diskSizeMB * 1024 * 1024
+	if override != "" {
+		if p, ok := strings.CutSuffix(override, "%"); ok {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil || v <= 0 || v > 100 {
+				return t, fmt.Errorf("invalid percent %q for %s", override, t.label())
+			}
+			percent = v
+		} else {
+			size, err := parseSize(override)
+			if err != nil {
+				return t, err
+			}
+			if size <= 0 {
+				return t, fmt.Errorf("size for %s must be positive", t.label())
+			}
+			t.sizeBytes, percent = size, 0
+		}
+	} else if freeLeft > 0 || diskUsedPercent > 0 {
+		t.freeLeft = freeLeft
+		if diskUsedPercent > 0 {
+			stats, err := disk.Usage(t.usagePath)
+			if err != nil {
+				return t, fmt.Errorf("query disk usage: %w", err)
+			}
+			t.freeLeft = int64(float64(stats.Total) * (100 - diskUsedPercent) / 100)
+		}
+		if t.freeLeft < diskSafetyMargin {
+			fmt.Printf("Keeping %d MB free on %s as a safety margin.\n", diskSafetyMargin>>20, t.label())
+			t.freeLeft = diskSafetyMargin
+		}
+		return t, nil
+	}
+
+	if percent > 0 {
+		stats, err := disk.Usage(t.usagePath)
+		if err != nil {
+			return t, fmt.Errorf("query disk usage: %w", err)
+		}
+		t.sizeBytes = int64(float64(stats.Total) * percent / 100)
+
+		maxBytes := int64(0)
+		if stats.Free > diskSafetyMargin {
+			maxBytes = int64(stats.Free - diskSafetyMargin)
+		}
+		if maxBytes > 0 && t.sizeBytes > maxBytes {
+			t.sizeBytes = maxBytes
+		}
+	}
+	return t, nil
+}
+
 func init() {
 	createCmd.AddCommand(diskCmd)
 	diskCmd.AddCommand(diskFillCmd)
 	mustBindFlags(diskFillCmd, spec.MustActionSpec("disk", "fill"), map[string]any{
-		"size":    &diskSizeMB,
-		"path":    &diskPath,
-		"percent": &diskPercent,
+		"size":         &diskSizeMB,
+		"path":         &diskPaths,
+		"volumes":      &diskVolumes,
+		"percent":      &diskPercent,
+		"free-left":    &diskFreeLeft,
+		"used-percent": &diskUsedPercent,
+		"method":       &diskMethod,
+		"content":      &diskContent,
+		"rate":         &diskRate,
 	})
 	diskFillCmd.Flags().BoolVar(&diskDetach, "detach", false, "run experiment detached (returns immediately)")
 	diskFillCmd.Flags().BoolVar(&diskDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
 	_ = diskFillCmd.Flags().MarkHidden("detached-child")
 }
+
+// warnUnaccountedFill prints the runner's fill warning once its initial fill completes.
+func warnUnaccountedFill(ctx context.Context, runner *exec.DiskFillRunner, label string) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-runner.Filled():
+	}
+	if err := runner.FillWarning(); err != nil {
+		fmt.Printf("Warning: %s: %v\n", label, err)
+	}
+}
+
+// printFillProgress prints how much of a fixed-size fill has been written until it
+// completes or ctx ends. A non-empty label names the path when several are filled.
+func printFillProgress(ctx context.Context, runner *exec.DiskFillRunner, sizeBytes int64, label string) {
+	suffix := ""
+	if label != "" {
+		suffix = " on " + label
+	}
+	started := time.Now()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		written := runner.WrittenBytes()
+		if written >= sizeBytes {
+			return
+		}
+		eta := ""
+		if d, ok := fillETA(written, sizeBytes, time.Since(started)); ok {
+			eta = ", ETA " + d.String()
+		}
+		fmt.Printf("Written %.1f of %.1f MB (%.0f%%%s)%s\n", float64(written)/1024.0/1024.0, float64(sizeBytes)/1024.0/1024.0, float64(written)*100/float64(sizeBytes), eta, suffix)
+	}
+}
+
+// fillETA estimates the time left to reach goal bytes from the average rate so far.
+// It reports false until there is progress to extrapolate from or once goal is reached.
+func fillETA(written, goal int64, elapsed time.Duration) (time.Duration, bool) {
+	if written <= 0 || written >= goal || elapsed <= 0 {
+		return 0, false
+	}
+	perByte := float64(elapsed) / float64(written)
+	return time.Duration(perByte * float64(goal-written)).Round(time.Second), true
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/spec"
+
+	"github.com/spf13/cobra"
+)
+
+var diskBurnRead bool
+var diskBurnWrite bool
+var diskBurnReadPercent int
+var diskBurnBlockSize string
+var diskBurnIODepth int
+var diskBurnPattern string
+var diskBurnPath string
+var diskBurnFileSize string
+var diskBurnDirect bool
+var diskBurnDetach bool
+var diskBurnDetachedChild bool
+
+var diskBurnCmd = &cobra.Command{
+	Use:     "burn",
+	Short:   spec.MustActionSpec("disk", "burn").Short,
+	Long:    spec.MustActionSpec("disk", "burn").Long,
+	Example: "chaosblade-win create disk burn --read --write --block-size 4k --iodepth 8 --pattern random --path D:\\",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !diskBurnRead && !diskBurnWrite {
+			return fmt.Errorf("at least one of --read or --write is required")
+		}
+		if diskBurnReadPercent < 0 || diskBurnReadPercent > 100 {
+			return fmt.Errorf("read-percent must be between 0 and 100")
+		}
+		if diskBurnPattern != "random" && diskBurnPattern != "sequential" {
+			return fmt.Errorf("pattern must be random or sequential")
+		}
+		if diskBurnIODepth < 1 {
+			return fmt.Errorf("iodepth must be at least 1")
+		}
+		blockSize, err := parseSize(diskBurnBlockSize)
+		if err != nil {
+			return err
+		}
+		fileSize, err := parseSize(diskBurnFileSize)
+		if err != nil {
+			return err
+		}
+		if blockSize <= 0 || fileSize < blockSize {
+			return fmt.Errorf("file-size must be at least one block-size")
+		}
+
+		if diskBurnDetach && !diskBurnDetachedChild {
+			args := []string{"create", "disk", "burn", "--read=" + strconv.FormatBool(diskBurnRead), "--write=" + strconv.FormatBool(diskBurnWrite), "--read-percent", strconv.Itoa(diskBurnReadPercent), "--block-size", diskBurnBlockSize, "--iodepth", strconv.Itoa(diskBurnIODepth), "--pattern", diskBurnPattern, "--path", diskBurnPath, "--file-size", diskBurnFileSize, "--direct=" + strconv.FormatBool(diskBurnDirect), "--detached-child"}
+			pid, err := exec.StartDetachedExperiment(args)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Started detached experiment pid=%d\n", pid)
+			return nil
+		}
+
+		id, cleanup, err := exec.TrackExperiment("disk", "burn", map[string]string{
+			"read":        strconv.FormatBool(diskBurnRead),
+			"write":       strconv.FormatBool(diskBurnWrite),
+			"readPercent": strconv.Itoa(diskBurnReadPercent),
+			"blockSize":   strconv.FormatInt(blockSize, 10),
+			"iodepth":     strconv.Itoa(diskBurnIODepth),
+			"pattern":     diskBurnPattern,
+			"path":        diskBurnPath,
+			"fileSize":    strconv.FormatInt(fileSize, 10),
+			"direct":      strconv.FormatBool(diskBurnDirect),
+		})
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		fmt.Printf("Started experiment id=%s\n", id)
+
+		runner := exec.NewDiskIOPSRunner(diskBurnPath, diskBurnRead, diskBurnWrite, int(blockSize), diskBurnIODepth, diskBurnPattern == "sequential")
+		runner.ReadPercent = diskBurnReadPercent
+		runner.FileSize = fileSize
+		runner.Direct = diskBurnDirect
+		runner.TrackArtifacts("disk", id)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		reportStatus(ctx, "disk", id, func() map[string]string {
+			s := runner.Stats()
+			return map[string]string{
+				"readOps":       strconv.FormatInt(s.ReadOps, 10),
+				"writeOps":      strconv.FormatInt(s.WriteOps, 10),
+				"iops":          fmt.Sprintf("%.0f", s.IOPS()),
+				"throughputMBs": fmt.Sprintf("%.2f", s.Throughput()/1024.0/1024.0),
+			}
+		})
+
+		target := diskBurnPath
+		if target == "" {
+			target = os.TempDir()
+		}
+		fmt.Printf("Burning %s IO on %s (block=%d iodepth=%d direct=%t). Press Ctrl+C to stop.\n", diskBurnPattern, target, blockSize, diskBurnIODepth, diskBurnDirect)
+		runErr := runner.Run(ctx)
+
+		s := runner.Stats()
+		fmt.Printf("Completed %d reads and %d writes in %s: %.0f IOPS, %.2f MB/s.\n", s.ReadOps, s.WriteOps, s.Elapsed.Round(time.Millisecond), s.IOPS(), s.Throughput()/1024.0/1024.0)
+		if runErr != nil && runErr != context.Canceled {
+			return runErr
+		}
+		fmt.Println("Disk burn stopped and cleaned up.")
+		return nil
+	},
+}
+
+func init() {
+	diskCmd.AddCommand(diskBurnCmd)
+	mustBindFlags(diskBurnCmd, spec.MustActionSpec("disk", "burn"), map[string]any{
+		"read":         &diskBurnRead,
+		"write":        &diskBurnWrite,
+		"read-percent": &diskBurnReadPercent,
+		"block-size":   &diskBurnBlockSize,
+		"iodepth":      &diskBurnIODepth,
+		"pattern":      &diskBurnPattern,
+		"path":         &diskBurnPath,
+		"file-size":    &diskBurnFileSize,
+		"direct":       &diskBurnDirect,
+	})
+	diskBurnCmd.Flags().BoolVar(&diskBurnDetach, "detach", false, "run experiment detached (returns immediately)")
+	diskBurnCmd.Flags().BoolVar(&diskBurnDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
+	_ = diskBurnCmd.Flags().MarkHidden("detached-child")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/spec"
+
+	"github.com/spf13/cobra"
+)
+
+var diskDelayPath string
+var diskDelayRead time.Duration
+var diskDelayWrite time.Duration
+var diskDelayPercent float64
+var diskDelayBackend string
+var diskDelayDetach bool
+var diskDelayDetachedChild bool
+
+var diskDelayCmd = &cobra.Command{
+	Use:     "delay",
+	Short:   spec.MustActionSpec("disk", "delay").Short,
+	Long:    spec.MustActionSpec("disk", "delay").Long,
+	Example: "chaosblade-win create disk delay --path /var/lib/app --read-latency 50ms --write-latency 200ms",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diskDelayPath == "" {
+			return fmt.Errorf("--path is required")
+		}
+		info, err := os.Stat(diskDelayPath)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", diskDelayPath)
+		}
+		if diskDelayRead < 0 || diskDelayWrite < 0 {
+			return fmt.Errorf("latencies must not be negative")
+		}
+		if diskDelayRead == 0 && diskDelayWrite == 0 {
+			return fmt.Errorf("set --read-latency and/or --write-latency")
+		}
+		if diskDelayPercent < 0 || diskDelayPercent > 100 {
+			return fmt.Errorf("percent must be between 0 and 100")
+		}
+
+		if diskDelayDetach && !diskDelayDetachedChild {
+			args := []string{"create", "disk", "delay", "--path", diskDelayPath, "--read-latency", diskDelayRead.String(), "--write-latency", diskDelayWrite.String(), "--percent", strconv.FormatFloat(diskDelayPercent, 'f', -1, 64), "--backend", diskDelayBackend, "--detached-child"}
+			pid, err := exec.StartDetachedExperiment(args)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Started detached experiment pid=%d\n", pid)
+			return nil
+		}
+
+		var rules []exec.FSFaultRule
+		if diskDelayRead > 0 {
+			rules = append(rules, exec.FSFaultRule{Ops: []exec.FSOp{exec.FSOpRead}, Latency: diskDelayRead, Percent: diskDelayPercent})
+		}
+		if diskDelayWrite > 0 {
+			rules = append(rules, exec.FSFaultRule{Ops: []exec.FSOp{exec.FSOpWrite, exec.FSOpFsync}, Latency: diskDelayWrite, Percent: diskDelayPercent})
+		}
+
+		id, cleanup, err := exec.TrackExperiment("disk", "delay", map[string]string{
+			"path":         diskDelayPath,
+			"readLatency":  diskDelayRead.String(),
+			"writeLatency": diskDelayWrite.String(),
+			"percent":      strconv.FormatFloat(diskDelayPercent, 'f', 2, 64),
+			"backend":      diskDelayBackend,
+		})
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		// Recorded before mounting so destroy unmounts even if this process is killed.
+		if err := exec.AddExperimentMount("disk", id, diskDelayPath); err != nil {
+			return err
+		}
+		fmt.Printf("Started experiment id=%s\n", id)
+
+		runner := exec.NewFSFaultRunner(diskDelayPath, diskDelayBackend, exec.NewFSFaultInjector(rules...))
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		reportStatus(ctx, "disk", id, func() map[string]string {
+			return map[string]string{
+				"delayedOps": strconv.FormatInt(runner.Injected(), 10),
+			}
+		})
+
+		fmt.Printf("Delaying IO under %s (read +%s, write +%s). Press Ctrl+C to stop.\n", diskDelayPath, diskDelayRead, diskDelayWrite)
+		if err := runner.Run(ctx); err != nil && err != context.Canceled {
+			return err
+		}
+		fmt.Printf("Delayed %d operation(s); %s unmounted.\n", runner.Injected(), diskDelayPath)
+		return nil
+	},
+}
+
+func init() {
+	diskCmd.AddCommand(diskDelayCmd)
+	mustBindFlags(diskDelayCmd, spec.MustActionSpec("disk", "delay"), map[string]any{
+		"path":          &diskDelayPath,
+		"read-latency":  &diskDelayRead,
+		"write-latency": &diskDelayWrite,
+		"percent":       &diskDelayPercent,
+		"backend":       &diskDelayBackend,
+	})
+	diskDelayCmd.Flags().BoolVar(&diskDelayDetach, "detach", false, "run experiment detached (returns immediately)")
+	diskDelayCmd.Flags().BoolVar(&diskDelayDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
+	_ = diskDelayCmd.Flags().MarkHidden("detached-child")
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/spec"
+
+	"github.com/spf13/cobra"
+)
+
+var diskFilesCount int64
+var diskFilesDir string
+var diskFilesSize string
+var diskFilesPerDir int64
+var diskFilesDetach bool
+var diskFilesDetachedChild bool
+
+var diskFilesCmd = &cobra.Command{
+	Use:     "files",
+	Short:   spec.MustActionSpec("disk", "files").Short,
+	Long:    spec.MustActionSpec("disk", "files").Long,
+	Example: "chaosblade-win create disk files --count 1000000 --dir D:\\data --size 0 --per-dir 1000",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diskFilesCount < 1 {
+			return fmt.Errorf("count must be at least 1")
+		}
+		if diskFilesPerDir < 0 {
+			return fmt.Errorf("per-dir must be zero or positive")
+		}
+		size, err := parseSize(diskFilesSize)
+		if err != nil {
+			return err
+		}
+
+		if diskFilesDetach && !diskFilesDetachedChild {
+			args := []string{"create", "disk", "files", "--count", strconv.FormatInt(diskFilesCount, 10), "--dir", diskFilesDir, "--size", diskFilesSize, "--per-dir", strconv.FormatInt(diskFilesPerDir, 10), "--detached-child"}
+			pid, err := exec.StartDetachedExperiment(args)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Started detached experiment pid=%d\n", pid)
+			return nil
+		}
+
+		id, cleanup, err := exec.TrackExperiment("disk", "files", map[string]string{
+			"count":  strconv.FormatInt(diskFilesCount, 10),
+			"dir":    diskFilesDir,
+			"size":   strconv.FormatInt(size, 10),
+			"perDir": strconv.FormatInt(diskFilesPerDir, 10),
+		})
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		// Files always go into a fresh subdirectory so destroy can remove the whole
+		// tree without touching anything that was already in --dir.
+		root, err := os.MkdirTemp(diskFilesDir, "chaosblade-files-*")
+		if err != nil {
+			return err
+		}
+		if err := exec.AddExperimentArtifact("disk", id, root); err != nil {
+			os.RemoveAll(root)
+			return err
+		}
+		fmt.Printf("Started experiment id=%s\n", id)
+
+		runner := exec.NewDiskFilesRunner(root, diskFilesCount, size, diskFilesPerDir)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		reportStatus(ctx, "disk", id, func() map[string]string {
+			status := map[string]string{
+				"root":         root,
+				"createdFiles": strconv.FormatInt(runner.CreatedFiles(), 10),
+			}
+			if err := runner.StopErr(); err != nil {
+				status["stoppedEarly"] = err.Error()
+			}
+			return status
+		})
+
+		fmt.Printf("Creating %d file(s) of %d bytes under %s. Press Ctrl+C to stop.\n", diskFilesCount, size, root)
+		if err := runner.Run(ctx); err != nil && err != context.Canceled {
+			return err
+		}
+		if err := runner.StopErr(); err != nil {
+			fmt.Printf("File creation stopped after %d file(s): %v\n", runner.CreatedFiles(), err)
+		}
+		fmt.Println("Disk files removed.")
+		return nil
+	},
+}
+
+func init() {
+	diskCmd.AddCommand(diskFilesCmd)
+	mustBindFlags(diskFilesCmd, spec.MustActionSpec("disk", "files"), map[string]any{
+		"count":   &diskFilesCount,
+		"dir":     &diskFilesDir,
+		"size":    &diskFilesSize,
+		"per-dir": &diskFilesPerDir,
+	})
+	diskFilesCmd.Flags().BoolVar(&diskFilesDetach, "detach", false, "run experiment detached (returns immediately)")
+	diskFilesCmd.Flags().BoolVar(&diskFilesDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
+	_ = diskFilesCmd.Flags().MarkHidden("detached-child")
+}
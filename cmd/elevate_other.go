@@ -0,0 +1,21 @@
+//go:build !windows
+
+package cmd
+
+import "os"
+
+// IsElevated reports whether the process runs as root.
+func IsElevated() bool {
+	return os.Geteuid() == 0
+}
+
+// EnsureElevated cannot relaunch with elevation outside Windows; run the command with
+// sudo instead. It always returns false.
+func EnsureElevated() bool {
+	return false
+}
+
+// RequestElevationIfNeeded is a no-op outside Windows.
+func RequestElevationIfNeeded(err error) bool {
+	return false
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/spec"
+
+	"github.com/spf13/cobra"
+)
+
+var fileFaultPath string
+var fileFaultOps string
+var fileFaultGlob string
+var fileFaultError string
+var fileFaultLatency time.Duration
+var fileFaultShortRead int
+var fileFaultPercent float64
+var fileFaultReadOnly bool
+var fileFaultBackend string
+var fileFaultDetach bool
+var fileFaultDetachedChild bool
+
+var fileTargetSpec = spec.Registry["file"]
+
+var fileCmd = &cobra.Command{
+	Use:   "file",
+	Short: fileTargetSpec.Short,
+}
+
+var fileFaultCmd = &cobra.Command{
+	Use:     "fault",
+	Short:   spec.MustActionSpec("file", "fault").Short,
+	Long:    spec.MustActionSpec("file", "fault").Long,
+	Example: "chaosblade-win create file fault --path /var/lib/app --ops write,fsync --glob '*.db' --error ENOSPC --percent 20",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if fileFaultPath == "" {
+			return fmt.Errorf("--path is required")
+		}
+		info, err := os.Stat(fileFaultPath)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", fileFaultPath)
+		}
+		ops, err := exec.ParseFSOps(fileFaultOps)
+		if err != nil {
+			return err
+		}
+		globs, err := exec.ParseFSGlobs(fileFaultGlob)
+		if err != nil {
+			return err
+		}
+		errno, err := exec.ParseFSErrno(fileFaultError)
+		if err != nil {
+			return err
+		}
+		if fileFaultPercent < 0 || fileFaultPercent > 100 {
+			return fmt.Errorf("percent must be between 0 and 100")
+		}
+		if fileFaultLatency < 0 || fileFaultShortRead < 0 {
+			return fmt.Errorf("latency and short-read must not be negative")
+		}
+		if errno == 0 && fileFaultLatency == 0 && fileFaultShortRead == 0 && !fileFaultReadOnly {
+			return fmt.Errorf("nothing to inject: set --error, --latency, --short-read or --read-only")
+		}
+
+		if fileFaultDetach && !fileFaultDetachedChild {
+			args := []string{"create", "file", "fault", "--path", fileFaultPath, "--ops", fileFaultOps, "--glob", fileFaultGlob, "--error", fileFaultError, "--latency", fileFaultLatency.String(), "--short-read", strconv.Itoa(fileFaultShortRead), "--percent", strconv.FormatFloat(fileFaultPercent, 'f', -1, 64), "--read-only=" + strconv.FormatBool(fileFaultReadOnly), "--backend", fileFaultBackend, "--detached-child"}
+			pid, err := exec.StartDetachedExperiment(args)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Started detached experiment pid=%d\n", pid)
+			return nil
+		}
+
+		var rules []exec.FSFaultRule
+		if errno != 0 || fileFaultLatency > 0 || fileFaultShortRead > 0 {
+			rules = append(rules, exec.FSFaultRule{
+				Ops:       ops,
+				Globs:     globs,
+				Errno:     errno,
+				Latency:   fileFaultLatency,
+				ShortRead: fileFaultShortRead,
+				Percent:   fileFaultPercent,
+			})
+		}
+		if fileFaultReadOnly {
+			rules = append(rules, exec.FSFaultRule{Ops: exec.FSMutatingOps, Globs: globs, Errno: syscall.EROFS, Percent: 100})
+		}
+
+		id, cleanup, err := exec.TrackExperiment("file", "fault", map[string]string{
+			"path":      fileFaultPath,
+			"ops":       fileFaultOps,
+			"glob":      fileFaultGlob,
+			"error":     fileFaultError,
+			"latency":   fileFaultLatency.String(),
+			"shortRead": strconv.Itoa(fileFaultShortRead),
+			"percent":   strconv.FormatFloat(fileFaultPercent, 'f', 2, 64),
+			"readOnly":  strconv.FormatBool(fileFaultReadOnly),
+			"backend":   fileFaultBackend,
+		})
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		// Recorded before mounting so destroy unmounts even if this process is killed.
+		if err := exec.AddExperimentMount("file", id, fileFaultPath); err != nil {
+			return err
+		}
+		fmt.Printf("Started experiment id=%s\n", id)
+
+		runner := exec.NewFSFaultRunner(fileFaultPath, fileFaultBackend, exec.NewFSFaultInjector(rules...))
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		reportStatus(ctx, "file", id, func() map[string]string {
+			return map[string]string{
+				"injected": strconv.FormatInt(runner.Injected(), 10),
+			}
+		})
+
+		fmt.Printf("Injecting faults into %s. Press Ctrl+C to stop.\n", fileFaultPath)
+		if err := runner.Run(ctx); err != nil && err != context.Canceled {
+			return err
+		}
+		fmt.Printf("Injected %d fault(s); %s unmounted.\n", runner.Injected(), fileFaultPath)
+		return nil
+	},
+}
+
+func init() {
+	createCmd.AddCommand(fileCmd)
+	fileCmd.AddCommand(fileFaultCmd)
+	mustBindFlags(fileFaultCmd, spec.MustActionSpec("file", "fault"), map[string]any{
+		"path":       &fileFaultPath,
+		"ops":        &fileFaultOps,
+		"glob":       &fileFaultGlob,
+		"error":      &fileFaultError,
+		"latency":    &fileFaultLatency,
+		"short-read": &fileFaultShortRead,
+		"percent":    &fileFaultPercent,
+		"read-only":  &fileFaultReadOnly,
+		"backend":    &fileFaultBackend,
+	})
+	fileFaultCmd.Flags().BoolVar(&fileFaultDetach, "detach", false, "run experiment detached (returns immediately)")
+	fileFaultCmd.Flags().BoolVar(&fileFaultDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
+	_ = fileFaultCmd.Flags().MarkHidden("detached-child")
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/spec"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags shared by every tamper action hold the same default, so one variable each is
+// enough; --size differs per action and therefore has separate variables.
+var fileTamperPath string
+var fileTamperDetach bool
+var fileTamperDetachedChild bool
+var fileAppendSize string
+var fileTruncateSize string
+var fileFlipCount int
+var fileRenameTo string
+var fileChmodMode string
+
+var fileAppendCmd = &cobra.Command{
+	Use:     "append",
+	Short:   spec.MustActionSpec("file", "append").Short,
+	Long:    spec.MustActionSpec("file", "append").Long,
+	Example: "chaosblade-win create file append --path C:\\app\\config.json --size 4k",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		size, err := parseSize(fileAppendSize)
+		if err != nil {
+			return err
+		}
+		if size <= 0 {
+			return fmt.Errorf("size must be positive")
+		}
+		return runFileTamper(exec.FileTamperAppend, []string{"--size", fileAppendSize}, map[string]string{
+			"size": strconv.FormatInt(size, 10),
+		}, func(r *exec.FileTamperRunner) {
+			r.Size = size
+		})
+	},
+}
+
+var fileTruncateCmd = &cobra.Command{
+	Use:     "truncate",
+	Short:   spec.MustActionSpec("file", "truncate").Short,
+	Long:    spec.MustActionSpec("file", "truncate").Long,
+	Example: "chaosblade-win create file truncate --path C:\\app\\data.db --size 0",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		size, err := parseSize(fileTruncateSize)
+		if err != nil {
+			return err
+		}
+		if size < 0 {
+			return fmt.Errorf("size must not be negative")
+		}
+		return runFileTamper(exec.FileTamperTruncate, []string{"--size", fileTruncateSize}, map[string]string{
+			"size": strconv.FormatInt(size, 10),
+		}, func(r *exec.FileTamperRunner) {
+			r.Size = size
+		})
+	},
+}
+
+var fileFlipCmd = &cobra.Command{
+	Use:     "flip",
+	Short:   spec.MustActionSpec("file", "flip").Short,
+	Long:    spec.MustActionSpec("file", "flip").Long,
+	Example: "chaosblade-win create file flip --path C:\\app\\data.db --count 64",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if fileFlipCount < 1 {
+			return fmt.Errorf("count must be at least 1")
+		}
+		return runFileTamper(exec.FileTamperFlip, []string{"--count", strconv.Itoa(fileFlipCount)}, map[string]string{
+			"count": strconv.Itoa(fileFlipCount),
+		}, func(r *exec.FileTamperRunner) {
+			r.Count = fileFlipCount
+		})
+	},
+}
+
+var fileRenameCmd = &cobra.Command{
+	Use:     "rename",
+	Short:   spec.MustActionSpec("file", "rename").Short,
+	Long:    spec.MustActionSpec("file", "rename").Long,
+	Example: "chaosblade-win create file rename --path C:\\app\\config.json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to := fileRenameTo
+		if to == "" && fileTamperPath != "" {
+			to = fileTamperPath + ".chaosblade"
+		}
+		if to != "" {
+			abs, err := filepath.Abs(to)
+			if err != nil {
+				return err
+			}
+			to = abs
+			if _, err := os.Lstat(to); err == nil {
+				return fmt.Errorf("rename destination %s already exists", to)
+			}
+		}
+		return runFileTamper(exec.FileTamperRename, []string{"--to", fileRenameTo}, map[string]string{
+			"to": to,
+		}, func(r *exec.FileTamperRunner) {
+			r.RenameTo = to
+		})
+	},
+}
+
+var fileLockCmd = &cobra.Command{
+	Use:     "lock",
+	Short:   spec.MustActionSpec("file", "lock").Short,
+	Long:    spec.MustActionSpec("file", "lock").Long,
+	Example: "chaosblade-win create file lock --path C:\\app\\data.db",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFileTamper(exec.FileTamperLock, nil, map[string]string{}, nil)
+	},
+}
+
+var fileChmodCmd = &cobra.Command{
+	Use:     "chmod",
+	Short:   spec.MustActionSpec("file", "chmod").Short,
+	Long:    spec.MustActionSpec("file", "chmod").Long,
+	Example: "chaosblade-win create file chmod --path C:\\app\\config.json --mode 444",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mode, err := strconv.ParseUint(fileChmodMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("mode must be octal permissions such as 000 or 444")
+		}
+		return runFileTamper(exec.FileTamperChmod, []string{"--mode", fileChmodMode}, map[string]string{
+			"mode": fmt.Sprintf("%03o", mode),
+		}, func(r *exec.FileTamperRunner) {
+			r.Mode = os.FileMode(mode)
+		})
+	},
+}
+
+// runFileTamper runs a tamper action on --path: it backs the file up into the
+// experiment state directory, applies the change, and restores it on stop. flagArgs
+// carries the action-specific flags forwarded to a detached child.
+func runFileTamper(action exec.FileTamperAction, flagArgs []string, params map[string]string, configure func(*exec.FileTamperRunner)) error {
+	if fileTamperPath == "" {
+		return fmt.Errorf("--path is required")
+	}
+	info, err := os.Stat(fileTamperPath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", fileTamperPath)
+	}
+
+	if fileTamperDetach && !fileTamperDetachedChild {
+		args := append([]string{"create", "file", string(action), "--path", fileTamperPath}, flagArgs...)
+		args = append(args, "--detached-child")
+		pid, err := exec.StartDetachedExperiment(args)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Started detached experiment pid=%d\n", pid)
+		return nil
+	}
+
+	params["path"] = fileTamperPath
+	id, cleanup, err := exec.TrackExperiment("file", string(action), params)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	backup, err := exec.BackupFile("file", id, fileTamperPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started experiment id=%s\n", id)
+	fmt.Printf("Backed up %s to %s\n", backup.Original, backup.Backup)
+
+	runner := exec.NewFileTamperRunner(action, backup)
+	if configure != nil {
+		configure(runner)
+	}
+	if runner.RenameTo != "" {
+		// The moved copy is redundant once the backup is restored; destroy removes it.
+		if err := exec.AddExperimentArtifact("file", id, runner.RenameTo); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Applied %s to %s. Press Ctrl+C to stop and restore.\n", action, backup.Original)
+	if err := runner.Run(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+	fmt.Printf("Restored %s.\n", backup.Original)
+	return nil
+}
+
+func init() {
+	for _, c := range []struct {
+		cmd   *cobra.Command
+		binds map[string]any
+	}{
+		{fileAppendCmd, map[string]any{"path": &fileTamperPath, "size": &fileAppendSize}},
+		{fileTruncateCmd, map[string]any{"path": &fileTamperPath, "size": &fileTruncateSize}},
+		{fileFlipCmd, map[string]any{"path": &fileTamperPath, "count": &fileFlipCount}},
+		{fileRenameCmd, map[string]any{"path": &fileTamperPath, "to": &fileRenameTo}},
+		{fileLockCmd, map[string]any{"path": &fileTamperPath}},
+		{fileChmodCmd, map[string]any{"path": &fileTamperPath, "mode": &fileChmodMode}},
+	} {
+		fileCmd.AddCommand(c.cmd)
+		mustBindFlags(c.cmd, spec.MustActionSpec("file", c.cmd.Name()), c.binds)
+		c.cmd.Flags().BoolVar(&fileTamperDetach, "detach", false, "run experiment detached (returns immediately)")
+		c.cmd.Flags().BoolVar(&fileTamperDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
+		_ = c.cmd.Flags().MarkHidden("detached-child")
+	}
+}
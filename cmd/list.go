@@ -23,7 +23,7 @@ var listCmd = &cobra.Command{
 			targets = []string{target}
 		} else {
 			// Show common targets
-			targets = []string{"cpu", "mem", "disk", "net"}
+			targets = []string{"cpu", "mem", "disk", "file", "net"}
 		}
 
 		for _, t := range targets {
@@ -42,6 +42,9 @@ var listCmd = &cobra.Command{
 					alive = "alive"
 				}
 				fmt.Printf("  id=%s pid=%d started=%s status=%s params=%v\n", s.ID, s.PID, s.StartedAt.Format(time.RFC3339), alive, s.Params)
+				if len(s.Status) > 0 {
+					fmt.Printf("    progress=%v\n", s.Status)
+				}
 			}
 		}
 		return nil
@@ -2,20 +2,34 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"chaosblade-win/exec"
 	"chaosblade-win/spec"
 
 	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/process"
 	"github.com/spf13/cobra"
 )
 
 var memSizeMB int64
 var memPercent float64
+var memMode string
+var memRate string
+var memMax string
+var memRampDuration time.Duration
+var memTargetUsed float64
+var memResident bool
+var memLock bool
+var memMinFree string
+var memCriticalFree string
+var memClamp bool
 var memDetach bool
 var memDetachedChild bool
 
@@ -32,16 +46,114 @@ var memLoadCmd = &cobra.Command{
 	Long:  spec.MustActionSpec("mem", "load").Long,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		sizeBytes := memSizeMB * 1024 * 1024
+		stats, err := mem.VirtualMemory()
+		if err != nil {
+			return fmt.Errorf("query memory: %w", err)
+		}
 		if memPercent > 0 {
-			stats, err := mem.VirtualMemory()
+			sizeBytes = int64(float64(stats.Total) * memPercent / 100)
+		}
+
+		minFree, err := parseSize(memMinFree)
+		if err != nil {
+			return err
+		}
+		criticalFree, err := parseSize(memCriticalFree)
+		if err != nil {
+			return err
+		}
+		if minFree > 0 && criticalFree > minFree {
+			return fmt.Errorf("critical-free must not exceed min-free")
+		}
+
+		var runner *exec.MemoryRunner
+		params := map[string]string{
+			"bytes":   fmt.Sprintf("%d", sizeBytes),
+			"percent": fmt.Sprintf("%.2f", memPercent),
+			"mode":    memMode,
+		}
+
+		mode := exec.MemoryMode(memMode)
+		if memTargetUsed > 0 {
+			if memTargetUsed > 100 {
+				return fmt.Errorf("target-used must be between 0 and 100")
+			}
+			mode = exec.MemoryModeTarget
+		}
+
+		// Every mode is held to available memory minus the min-free floor: the size for
+		// hold, ramp, cache and mapped, the --max ceiling for leak, and the usage target.
+		maxBytes := int64(stats.Available) - minFree
+		if mode != exec.MemoryModeLeak && mode != exec.MemoryModeTarget {
+			if sizeBytes, err = clampMemoryRequest(sizeBytes, maxBytes, minFree); err != nil {
+				return err
+			}
+			params["bytes"] = fmt.Sprintf("%d", sizeBytes)
+		}
+
+		switch mode {
+		case exec.MemoryModeTarget:
+			// The target is system-wide usage; the headroom above current usage must fit.
+			targetBytes := int64(float64(stats.Total) * memTargetUsed / 100)
+			wanted := targetBytes - int64(stats.Used)
+			if clamped, err := clampMemoryRequest(wanted, maxBytes, minFree); err != nil {
+				return err
+			} else if clamped < wanted {
+				memTargetUsed = float64(int64(stats.Used)+clamped) * 100 / float64(stats.Total)
+			}
+			runner = exec.NewMemoryTargetRunner(memTargetUsed)
+			params["mode"] = string(mode)
+			params["targetUsed"] = fmt.Sprintf("%.2f", memTargetUsed)
+		case exec.MemoryModeHold:
+			runner = exec.NewMemoryRunner(sizeBytes)
+		case exec.MemoryModeLeak:
+			rate, err := parseRate(memRate)
 			if err != nil {
-				return fmt.Errorf("query memory: %w", err)
+				return err
 			}
-			sizeBytes = int64(float64(stats.Total) * memPercent / 100)
+			if rate <= 0 {
+				return fmt.Errorf("leak mode requires --rate (e.g. 10MB/s)")
+			}
+			leakMax, err := parseSize(memMax)
+			if err != nil {
+				return err
+			}
+			if leakMax <= 0 {
+				leakMax = sizeBytes
+			}
+			if leakMax, err = clampMemoryRequest(leakMax, maxBytes, minFree); err != nil {
+				return err
+			}
+			runner = exec.NewMemoryLeakRunner(rate, leakMax)
+			params["rate"] = fmt.Sprintf("%d", rate)
+			params["max"] = fmt.Sprintf("%d", leakMax)
+		case exec.MemoryModeRamp:
+			if memRampDuration <= 0 {
+				return fmt.Errorf("ramp mode requires a positive --ramp-duration")
+			}
+			runner = exec.NewMemoryRampRunner(sizeBytes, memRampDuration)
+			params["rampDuration"] = memRampDuration.String()
+		case exec.MemoryModeCache, exec.MemoryModeMapped:
+			if memResident || memLock {
+				return fmt.Errorf("--resident and --lock apply to anonymous memory, not mode %s", memMode)
+			}
+			runner = exec.NewMemoryFileRunner(mode, sizeBytes)
+		default:
+			return fmt.Errorf("unknown mode %q (expected hold, leak, ramp, cache, or mapped)", memMode)
+		}
+
+		runner.SetGuard(minFree, criticalFree)
+		params["minFree"] = fmt.Sprintf("%d", minFree)
+		params["criticalFree"] = fmt.Sprintf("%d", criticalFree)
+
+		if memResident || memLock {
+			runner.UseResidentMemory(memLock)
+			params["resident"] = "true"
+			params["lock"] = strconv.FormatBool(memLock)
 		}
 
 		if memDetach && !memDetachedChild {
-			args := []string{"create", "mem", "load", "--size", fmt.Sprintf("%d", memSizeMB), "--detached-child"}
+			args := []string{"create", "mem", "load", "--size", fmt.Sprintf("%d", memSizeMB), "--percent", strconv.FormatFloat(memPercent, 'f', -1, 64), "--mode", memMode, "--rate", memRate, "--max", memMax, "--ramp-duration", memRampDuration.String(), "--target-used", strconv.FormatFloat(memTargetUsed, 'f', -1, 64), "--resident=" + strconv.FormatBool(memResident), "--lock=" + strconv.FormatBool(memLock), "--min-free", memMinFree, "--critical-free", memCriticalFree, "--clamp=" + strconv.FormatBool(memClamp), "--detached-child"}
 			pid, err := exec.StartDetachedExperiment(args)
 			if err != nil {
 				return err
@@ -50,23 +162,51 @@ var memLoadCmd = &cobra.Command{
 			return nil
 		}
 
-		id, cleanup, err := exec.TrackExperiment("mem", "load", map[string]string{
-			"bytes":   fmt.Sprintf("%d", sizeBytes),
-			"percent": fmt.Sprintf("%.2f", memPercent),
-		})
+		id, cleanup, err := exec.TrackExperiment("mem", "load", params)
 		if err != nil {
 			return err
 		}
+		runner.TrackArtifacts("mem", id)
 		defer cleanup()
 		fmt.Printf("Started experiment id=%s\n", id)
 
-		runner := exec.NewMemoryRunner(sizeBytes)
-
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
-		fmt.Printf("Allocating ~%.1f MB. Press Ctrl+C to stop.\n", float64(sizeBytes)/1024.0/1024.0)
+		reportStatus(ctx, "mem", id, func() map[string]string {
+			status := map[string]string{
+				"allocatedBytes": fmt.Sprintf("%d", runner.AllocatedBytes()),
+			}
+			if stats, err := mem.VirtualMemory(); err == nil {
+				status["hostUsedPercent"] = fmt.Sprintf("%.2f", stats.UsedPercent)
+			}
+			// RSS on Unix, working set on Windows: what actually sits in physical memory.
+			if proc, err := process.NewProcess(int32(os.Getpid())); err == nil {
+				if info, err := proc.MemoryInfo(); err == nil {
+					status["residentBytes"] = fmt.Sprintf("%d", info.RSS)
+				}
+			}
+			return status
+		})
+
+		switch runner.Mode() {
+		case exec.MemoryModeTarget:
+			fmt.Printf("Holding host memory usage at %.1f%%. Press Ctrl+C to stop.\n", memTargetUsed)
+		case exec.MemoryModeLeak:
+			fmt.Printf("Leaking memory at %s/s up to ~%.1f MB. Press Ctrl+C to stop.\n", memRate, float64(runner.TargetBytes())/1024.0/1024.0)
+		case exec.MemoryModeCache:
+			fmt.Printf("Filling page cache with ~%.1f MB of temporary file data. Press Ctrl+C to stop.\n", float64(runner.TargetBytes())/1024.0/1024.0)
+		case exec.MemoryModeMapped:
+			fmt.Printf("Mapping ~%.1f MB temporary file into memory. Press Ctrl+C to stop.\n", float64(runner.TargetBytes())/1024.0/1024.0)
+		case exec.MemoryModeRamp:
+			fmt.Printf("Ramping to ~%.1f MB over %s. Press Ctrl+C to stop.\n", float64(runner.TargetBytes())/1024.0/1024.0, memRampDuration)
+		default:
+			fmt.Printf("Allocating ~%.1f MB. Press Ctrl+C to stop.\n", float64(runner.TargetBytes())/1024.0/1024.0)
+		}
 		if err := runner.Run(ctx); err != nil && err != context.Canceled {
+			if errors.Is(err, exec.ErrMemoryCritical) {
+				fmt.Println("Memory watchdog released the allocation.")
+			}
 			return err
 		}
 		fmt.Println("Memory load stopped.")
@@ -74,12 +214,36 @@ var memLoadCmd = &cobra.Command{
 	},
 }
 
+// clampMemoryRequest checks size against maxBytes (available memory minus the min-free
+// floor), refusing it or, with --clamp, lowering it.
+func clampMemoryRequest(size, maxBytes, minFree int64) (int64, error) {
+	if size <= maxBytes {
+		return size, nil
+	}
+	if !memClamp || maxBytes <= 0 {
+		return 0, fmt.Errorf("requested %.1f MB exceeds available memory minus the %.1f MB floor (%.1f MB); lower the size or pass --clamp",
+			float64(size)/1024.0/1024.0, float64(minFree)/1024.0/1024.0, float64(max(maxBytes, 0))/1024.0/1024.0)
+	}
+	fmt.Printf("Clamping request to %.1f MB to keep %.1f MB free.\n", float64(maxBytes)/1024.0/1024.0, float64(minFree)/1024.0/1024.0)
+	return maxBytes, nil
+}
+
 func init() {
 	createCmd.AddCommand(memCmd)
 	memCmd.AddCommand(memLoadCmd)
 	mustBindFlags(memLoadCmd, spec.MustActionSpec("mem", "load"), map[string]any{
-		"size":    &memSizeMB,
-		"percent": &memPercent,
+		"size":          &memSizeMB,
+		"percent":       &memPercent,
+		"mode":          &memMode,
+		"rate":          &memRate,
+		"max":           &memMax,
+		"ramp-duration": &memRampDuration,
+		"target-used":   &memTargetUsed,
+		"resident":      &memResident,
+		"lock":          &memLock,
+		"min-free":      &memMinFree,
+		"critical-free": &memCriticalFree,
+		"clamp":         &memClamp,
 	})
 	memLoadCmd.Flags().BoolVar(&memDetach, "detach", false, "run experiment detached (returns immediately)")
 	memLoadCmd.Flags().BoolVar(&memDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
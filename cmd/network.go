@@ -17,6 +17,7 @@ import (
 var netTargetSpec = spec.Registry["net"]
 var netDelayAction = spec.MustActionSpec("net", "delay")
 var netDefaultFilter = stringDefault(netDelayAction.Flags, "filter", "true")
+var netTarget netTargetFlags
 var netDetach bool
 var netDetachedChild bool
 
@@ -46,14 +47,83 @@ var netDelayCmd = &cobra.Command{
 		if netLossPercent < 0 || netLossPercent > 100 {
 			return fmt.Errorf("loss must be between 0 and 100")
 		}
-		if netFilter == "" {
-			netFilter = netDefaultFilter
+		if netDuplicatePercent < 0 || netDuplicatePercent > 100 || netReorderPercent < 0 || netReorderPercent > 100 {
+			return fmt.Errorf("duplicate and reorder must be between 0 and 100")
+		}
+		if netReorderGap < 1 {
+			return fmt.Errorf("reorder-gap must be at least 1")
+		}
+		filter, err := netTarget.filter(cmd, netFilter, netReplay != "")
+		if err != nil {
+			return err
+		}
+		netFilter = filter
+
+		if netCapture != "" && netReplay == "" {
+			return fmt.Errorf("--capture requires --replay")
+		}
+
+		if netMaxQueued < 1 {
+			return fmt.Errorf("max-queued must be at least 1")
+		}
+
+		burst, err := parseSize(netBurst)
+		if err != nil {
+			return err
+		}
+		limitPackets, limitBytes, err := parseQueueLimit(netQueueLimit)
+		if err != nil {
+			return err
+		}
+		policy, err := exec.ParseQueuePolicy(netQueuePolicy)
+		if err != nil {
+			return err
+		}
+
+		if netDelayCorrelation < 0 || netDelayCorrelation > 100 {
+			return fmt.Errorf("delay-correlation must be between 0 and 100")
+		}
+		dist, err := netDelayDistribution()
+		if err != nil {
+			return err
+		}
+
+		lossKind, err := exec.ParseLossModelKind(netLossModel)
+		if err != nil {
+			return err
+		}
+		lossModel, err := exec.NewLossModel(lossKind, exec.LossParams{
+			Percent:     netLossPercent,
+			Correlation: netLossCorrelation,
+			P:           netGEP,
+			R:           netGER,
+			BadLoss:     netGEBadLoss,
+			GoodLoss:    netGEGoodLoss,
+		})
+		if err != nil {
+			return err
 		}
 
 		runner := exec.NewNetworkDelayRunner(netDelayMs, netJitterMs, netLossPercent, netFilter, netBandwidthKbps)
+		runner.MaxQueued = netMaxQueued
+		runner.BurstBytes = burst
+		runner.QueueLimitPackets = limitPackets
+		runner.QueueLimitBytes = limitBytes
+		runner.QueuePolicy = policy
+		runner.DuplicatePercent = netDuplicatePercent
+		runner.ReorderPercent = netReorderPercent
+		runner.ReorderGap = netReorderGap
+		runner.Seed = netSeed
+		runner.LossModel = lossModel
+		runner.Distribution = dist
+		runner.DelayCorrelation = netDelayCorrelation
+
+		if !netTarget.target.IsZero() {
+			fmt.Printf("Compiled filter: %s\n", netFilter)
+		}
 
 		if netDetach && !netDetachedChild {
-			args := []string{"create", "net", "delay", strconv.Itoa(netDelayMs), "--jitter", strconv.Itoa(netJitterMs), "--loss", fmt.Sprintf("%.2f", netLossPercent), "--filter", netFilter, "--detached-child"}
+			args := []string{"create", "net", "delay", strconv.Itoa(netDelayMs), "--jitter", strconv.Itoa(netJitterMs), "--distribution", netDistribution, "--distribution-file", netDistributionFile, "--delay-correlation", strconv.FormatFloat(netDelayCorrelation, 'f', -1, 64), "--loss", fmt.Sprintf("%.2f", netLossPercent), "--loss-model", string(lossKind), "--loss-correlation", strconv.FormatFloat(netLossCorrelation, 'f', -1, 64), "--ge-p", strconv.FormatFloat(netGEP, 'f', -1, 64), "--ge-r", strconv.FormatFloat(netGER, 'f', -1, 64), "--ge-bad-loss", strconv.FormatFloat(netGEBadLoss, 'f', -1, 64), "--ge-good-loss", strconv.FormatFloat(netGEGoodLoss, 'f', -1, 64), "--bandwidth", strconv.Itoa(netBandwidthKbps), "--filter", netFilter, "--burst", netBurst, "--queue-limit", netQueueLimit, "--queue-policy", netQueuePolicy, "--max-queued", strconv.Itoa(netMaxQueued), "--duplicate", strconv.FormatFloat(netDuplicatePercent, 'f', -1, 64), "--reorder", strconv.FormatFloat(netReorderPercent, 'f', -1, 64), "--reorder-gap", strconv.Itoa(netReorderGap), "--seed", strconv.FormatInt(netSeed, 10), "--replay", netReplay, "--capture", netCapture, "--detached-child"}
 			pid, err := exec.StartDetachedExperiment(args)
 			if err != nil {
 				return err
@@ -62,13 +132,27 @@ var netDelayCmd = &cobra.Command{
 			return nil
 		}
 
-		id, cleanup, err := exec.TrackExperiment("net", "delay", map[string]string{
-			"delay":         strconv.Itoa(netDelayMs),
-			"jitter":        strconv.Itoa(netJitterMs),
-			"loss":          fmt.Sprintf("%.2f", netLossPercent),
-			"filter":        netFilter,
-			"bandwidthKbps": strconv.Itoa(netBandwidthKbps),
-		})
+		id, cleanup, err := exec.TrackExperiment("net", "delay", netTarget.params(map[string]string{
+			"delay":            strconv.Itoa(netDelayMs),
+			"jitter":           strconv.Itoa(netJitterMs),
+			"distribution":     dist.Name,
+			"delayCorrelation": strconv.FormatFloat(netDelayCorrelation, 'f', -1, 64),
+			"loss":             fmt.Sprintf("%.2f", netLossPercent),
+			"lossModel":        string(lossKind),
+			"lossRate":         fmt.Sprintf("%.2f", lossModel.Rate()*100),
+			"filter":           netFilter,
+			"bandwidthKbps":    strconv.Itoa(netBandwidthKbps),
+			"burst":            strconv.FormatInt(burst, 10),
+			"queueLimit":       netQueueLimit,
+			"queuePolicy":      string(policy),
+			"maxQueued":        strconv.Itoa(netMaxQueued),
+			"duplicate":        strconv.FormatFloat(netDuplicatePercent, 'f', -1, 64),
+			"reorder":          strconv.FormatFloat(netReorderPercent, 'f', -1, 64),
+			"reorderGap":       strconv.Itoa(netReorderGap),
+			"seed":             strconv.FormatInt(netSeed, 10),
+			"replay":           netReplay,
+			"capture":          netCapture,
+		}))
 		if err != nil {
 			return err
 		}
@@ -79,38 +163,162 @@ var netDelayCmd = &cobra.Command{
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
-		fmt.Printf("Requested net delay=%dms jitter=%dms loss=%.2f%% bandwidth=%dkbps filter=%q. WinDivert must be installed. Press Ctrl+C to stop.\n", netDelayMs, netJitterMs, netLossPercent, netBandwidthKbps, netFilter)
+		reportStatus(ctx, "net", id, func() map[string]string {
+			return netStatus(runner.Stats())
+		})
+
+		if netReplay != "" {
+			dev, err := exec.OpenPcapPacketDevice(netReplay, netCapture, false, true)
+			if err != nil {
+				return err
+			}
+			runner.Device = dev
+			fmt.Printf("Replaying %s with delay=%dms jitter=%dms loss=%.2f%% bandwidth=%dkbps.\n", netReplay, netDelayMs, netJitterMs, netLossPercent, netBandwidthKbps)
+		} else {
+			fmt.Printf("Requested net delay=%dms jitter=%dms loss=%.2f%% bandwidth=%dkbps filter=%q. WinDivert must be installed. Press Ctrl+C to stop.\n", netDelayMs, netJitterMs, netLossPercent, netBandwidthKbps, netFilter)
+		}
 		if err := runner.Run(ctx); err != nil && err != context.Canceled {
 			return err
 		}
+		st := runner.Stats()
+		fmt.Printf("Received %d packet(s), sent %d, lost %d, dropped %d on queue overflow.\n", st.Received, st.Sent, st.Lost, st.Overflow)
+		if lossKind != exec.LossBernoulli {
+			fmt.Printf("Loss model %s expects %.2f%% loss; observed %.2f%%.\n", lossKind, lossModel.Rate()*100, percentOf(st.Lost, st.Received))
+		}
+		if st.Bypassed > 0 {
+			fmt.Printf("Passed %d packet(s) not matching the filter through untouched.\n", st.Bypassed)
+		}
+		if netDuplicatePercent > 0 || netReorderPercent > 0 {
+			fmt.Printf("Duplicated %d and reordered %d packet(s).\n", st.Duplicated, st.Reordered)
+		}
+		if netBandwidthKbps > 0 {
+			fmt.Printf("Shaper dropped %d outbound and %d inbound packet(s).\n", st.Outbound.Dropped, st.Inbound.Dropped)
+		}
 		return nil
 	},
 }
 
 var (
-	netDelayMs       int
-	netJitterMs      int
-	netLossPercent   float64
-	netFilter        string
-	netBandwidthKbps int
+	netDelayMs          int
+	netJitterMs         int
+	netDistribution     string
+	netDistributionFile string
+	netDelayCorrelation float64
+	netLossPercent      float64
+	netLossModel        string
+	netLossCorrelation  float64
+	netGEP              float64
+	netGER              float64
+	netGEBadLoss        float64
+	netGEGoodLoss       float64
+	netFilter           string
+	netBandwidthKbps    int
+	netBurst            string
+	netQueueLimit       string
+	netQueuePolicy      string
+	netMaxQueued        int
+	netDuplicatePercent float64
+	netReorderPercent   float64
+	netReorderGap       int
+	netSeed             int64
+	netReplay           string
+	netCapture          string
 )
 
 func init() {
 	createCmd.AddCommand(netCmd)
 	netCmd.AddCommand(netDelayCmd)
 
-	mustBindFlags(netDelayCmd, netDelayAction, map[string]any{
-		"delay":     &netDelayMs,
-		"jitter":    &netJitterMs,
-		"loss":      &netLossPercent,
-		"filter":    &netFilter,
-		"bandwidth": &netBandwidthKbps,
-	})
+	mustBindFlags(netDelayCmd, netDelayAction, netTarget.bind(map[string]any{
+		"delay":             &netDelayMs,
+		"jitter":            &netJitterMs,
+		"distribution":      &netDistribution,
+		"distribution-file": &netDistributionFile,
+		"delay-correlation": &netDelayCorrelation,
+		"loss":              &netLossPercent,
+		"loss-model":        &netLossModel,
+		"loss-correlation":  &netLossCorrelation,
+		"ge-p":              &netGEP,
+		"ge-r":              &netGER,
+		"ge-bad-loss":       &netGEBadLoss,
+		"ge-good-loss":      &netGEGoodLoss,
+		"filter":            &netFilter,
+		"bandwidth":         &netBandwidthKbps,
+		"burst":             &netBurst,
+		"queue-limit":       &netQueueLimit,
+		"queue-policy":      &netQueuePolicy,
+		"max-queued":        &netMaxQueued,
+		"duplicate":         &netDuplicatePercent,
+		"reorder":           &netReorderPercent,
+		"reorder-gap":       &netReorderGap,
+		"seed":              &netSeed,
+		"replay":            &netReplay,
+		"capture":           &netCapture,
+	}))
 	netDelayCmd.Flags().BoolVar(&netDetach, "detach", false, "run experiment detached (returns immediately)")
 	netDelayCmd.Flags().BoolVar(&netDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
 	_ = netDelayCmd.Flags().MarkHidden("detached-child")
 }
 
+// netStatus renders runner counters for the experiment status.
+func netStatus(st exec.NetworkStats) map[string]string {
+	return map[string]string{
+		"received":        strconv.FormatInt(st.Received, 10),
+		"sent":            strconv.FormatInt(st.Sent, 10),
+		"lost":            strconv.FormatInt(st.Lost, 10),
+		"overflowDropped": strconv.FormatInt(st.Overflow, 10),
+		"corrupted":       strconv.FormatInt(st.Corrupted, 10),
+		"duplicated":      strconv.FormatInt(st.Duplicated, 10),
+		"reordered":       strconv.FormatInt(st.Reordered, 10),
+		"bypassed":        strconv.FormatInt(st.Bypassed, 10),
+		"queued":          strconv.Itoa(st.Queued),
+		"outQueuePackets": strconv.Itoa(st.Outbound.QueuedPackets),
+		"outQueueBytes":   strconv.FormatInt(st.Outbound.QueuedBytes, 10),
+		"outShaperDrops":  strconv.FormatInt(st.Outbound.Dropped, 10),
+		"inQueuePackets":  strconv.Itoa(st.Inbound.QueuedPackets),
+		"inQueueBytes":    strconv.FormatInt(st.Inbound.QueuedBytes, 10),
+		"inShaperDrops":   strconv.FormatInt(st.Inbound.Dropped, 10),
+	}
+}
+
+// parseQueueLimit reads a shaper queue limit: a bare count is packets, a size such as
+// "64KB" is bytes.
+func parseQueueLimit(s string) (int, int64, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return 0, 0, fmt.Errorf("queue-limit must be at least 1 packet")
+		}
+		return n, 0, nil
+	}
+	b, err := parseSize(s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("queue-limit must be a packet count or a size: %w", err)
+	}
+	if b < 1 {
+		return 0, 0, fmt.Errorf("queue-limit must be positive")
+	}
+	return 0, b, nil
+}
+
+// netDelayDistribution resolves --distribution and --distribution-file.
+func netDelayDistribution() (*exec.DelayDistribution, error) {
+	if netDistributionFile == "" {
+		return exec.BuiltinDelayDistribution(netDistribution)
+	}
+	if netDistribution != "" && netDistribution != exec.DistUniform {
+		return nil, fmt.Errorf("--distribution and --distribution-file are mutually exclusive")
+	}
+	return exec.LoadDelayDistribution(netDistributionFile)
+}
+
+// percentOf returns n as a percentage of total, or 0 when total is 0.
+func percentOf(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
 func stringDefault(flags []spec.FlagSpec, name, fallback string) string {
 	for _, f := range flags {
 		if f.Name == name {
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/spec"
+
+	"github.com/spf13/cobra"
+)
+
+var netCorruptAction = spec.MustActionSpec("net", "corrupt")
+var netCorruptPercent float64
+var netCorruptMode string
+var netCorruptSeed int64
+var netCorruptFilter string
+var netCorruptReplay string
+var netCorruptCapture string
+var netCorruptTarget netTargetFlags
+var netCorruptDetach bool
+var netCorruptDetachedChild bool
+
+var netCorruptCmd = &cobra.Command{
+	Use:     "corrupt",
+	Short:   netCorruptAction.Short,
+	Long:    netCorruptAction.Long,
+	Example: "chaosblade-win create net corrupt --percent 2 --mode checksum-valid --filter \"outbound and tcp.DstPort == 443\"",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if netCorruptPercent <= 0 || netCorruptPercent > 100 {
+			return fmt.Errorf("percent must be greater than 0 and at most 100")
+		}
+		mode, err := exec.ParseCorruptMode(netCorruptMode)
+		if err != nil {
+			return err
+		}
+		filter, err := netCorruptTarget.filter(cmd, netCorruptFilter, netCorruptReplay != "")
+		if err != nil {
+			return err
+		}
+		netCorruptFilter = filter
+		if netCorruptCapture != "" && netCorruptReplay == "" {
+			return fmt.Errorf("--capture requires --replay")
+		}
+
+		if !netCorruptTarget.target.IsZero() {
+			fmt.Printf("Compiled filter: %s\n", netCorruptFilter)
+		}
+
+		if netCorruptDetach && !netCorruptDetachedChild {
+			args := []string{"create", "net", "corrupt", "--percent", strconv.FormatFloat(netCorruptPercent, 'f', -1, 64), "--mode", string(mode), "--seed", strconv.FormatInt(netCorruptSeed, 10), "--filter", netCorruptFilter, "--replay", netCorruptReplay, "--capture", netCorruptCapture, "--detached-child"}
+			pid, err := exec.StartDetachedExperiment(args)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Started detached experiment pid=%d\n", pid)
+			return nil
+		}
+
+		runner := exec.NewNetworkDelayRunner(0, 0, 0, netCorruptFilter, 0)
+		runner.CorruptPercent = netCorruptPercent
+		runner.CorruptMode = mode
+		runner.Seed = netCorruptSeed
+
+		id, cleanup, err := exec.TrackExperiment("net", "corrupt", netCorruptTarget.params(map[string]string{
+			"percent": strconv.FormatFloat(netCorruptPercent, 'f', -1, 64),
+			"mode":    string(mode),
+			"seed":    strconv.FormatInt(netCorruptSeed, 10),
+			"filter":  netCorruptFilter,
+			"replay":  netCorruptReplay,
+			"capture": netCorruptCapture,
+		}))
+		if err != nil {
+			return err
+		}
+
+		defer cleanup()
+		fmt.Printf("Started experiment id=%s\n", id)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		reportStatus(ctx, "net", id, func() map[string]string {
+			return netStatus(runner.Stats())
+		})
+
+		if netCorruptReplay != "" {
+			dev, err := exec.OpenPcapPacketDevice(netCorruptReplay, netCorruptCapture, false, true)
+			if err != nil {
+				return err
+			}
+			runner.Device = dev
+			fmt.Printf("Replaying %s with corrupt=%.2f%% mode=%s.\n", netCorruptReplay, netCorruptPercent, mode)
+		} else {
+			fmt.Printf("Requested net corrupt=%.2f%% mode=%s filter=%q. WinDivert must be installed. Press Ctrl+C to stop.\n", netCorruptPercent, mode, netCorruptFilter)
+		}
+		if err := runner.Run(ctx); err != nil && err != context.Canceled {
+			return err
+		}
+		st := runner.Stats()
+		fmt.Printf("Received %d packet(s), sent %d, corrupted %d, passed %d not matching the filter.\n", st.Received, st.Sent, st.Corrupted, st.Bypassed)
+		return nil
+	},
+}
+
+func init() {
+	netCmd.AddCommand(netCorruptCmd)
+
+	mustBindFlags(netCorruptCmd, netCorruptAction, netCorruptTarget.bind(map[string]any{
+		"percent": &netCorruptPercent,
+		"mode":    &netCorruptMode,
+		"seed":    &netCorruptSeed,
+		"filter":  &netCorruptFilter,
+		"replay":  &netCorruptReplay,
+		"capture": &netCorruptCapture,
+	}))
+	netCorruptCmd.Flags().BoolVar(&netCorruptDetach, "detach", false, "run experiment detached (returns immediately)")
+	netCorruptCmd.Flags().BoolVar(&netCorruptDetachedChild, "detached-child", false, "(internal) run as detached child and write state")
+	_ = netCorruptCmd.Flags().MarkHidden("detached-child")
+}
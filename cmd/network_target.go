@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"chaosblade-win/exec"
+	"chaosblade-win/internal/filter"
+
+	"github.com/spf13/cobra"
+)
+
+// netTargetFlags holds the ChaosBlade-style traffic selectors shared by net actions.
+type netTargetFlags struct {
+	target exec.NetTarget
+}
+
+// bind adds the selector bindings for mustBindFlags to binds.
+func (f *netTargetFlags) bind(binds map[string]any) map[string]any {
+	binds["remote-port"] = &f.target.RemotePorts
+	binds["local-port"] = &f.target.LocalPorts
+	binds["destination-ip"] = &f.target.DestinationIP
+	binds["exclude-port"] = &f.target.ExcludePorts
+	binds["exclude-ip"] = &f.target.ExcludeIPs
+	binds["protocol"] = &f.target.Protocol
+	binds["direction"] = &f.target.Direction
+	return binds
+}
+
+// filter resolves the WinDivert filter for cmd: without selectors it is the --filter
+// value; with them, the compiled selectors ANDed with --filter when it was given. The
+// result is parsed so mistakes are reported here rather than by WinDivert on the host.
+// For a live capture (replay false) WinDivert has the final say, so a filter this
+// parser rejects only produces a warning.
+func (f *netTargetFlags) filter(cmd *cobra.Command, expr string, replay bool) (string, error) {
+	if f.target.IsZero() {
+		if expr == "" {
+			expr = netDefaultFilter
+		}
+		return expr, validateNetFilter(expr, replay)
+	}
+	base := ""
+	if cmd.Flags().Changed("filter") {
+		if err := validateNetFilter(expr, replay); err != nil {
+			return "", err
+		}
+		base = expr
+	}
+	compiled, err := f.target.Filter(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid traffic selector: %w", err)
+	}
+	return compiled, validateNetFilter(compiled, replay)
+}
+
+// validateNetFilter parses expr, pointing at the offending position on error. Unless
+// the filter is needed in process for a replay, the error is printed as a warning.
+func validateNetFilter(expr string, replay bool) error {
+	_, err := filter.Parse(expr)
+	var se *filter.SyntaxError
+	if errors.As(err, &se) {
+		err = fmt.Errorf("invalid --filter: %w\n  %s", err, strings.ReplaceAll(se.Caret(), "\n", "\n  "))
+	}
+	if err != nil && !replay {
+		fmt.Printf("Warning: %v\n  (passing the filter to WinDivert as is)\n", err)
+		return nil
+	}
+	return err
+}
+
+// params records the selectors in experiment params, skipping unset ones.
+func (f *netTargetFlags) params(m map[string]string) map[string]string {
+	for k, v := range map[string]string{
+		"remotePort":    f.target.RemotePorts,
+		"localPort":     f.target.LocalPorts,
+		"destinationIP": f.target.DestinationIP,
+		"excludePort":   f.target.ExcludePorts,
+		"excludeIP":     f.target.ExcludeIPs,
+		"protocol":      f.target.Protocol,
+		"direction":     f.target.Direction,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
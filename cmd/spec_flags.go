@@ -116,6 +116,21 @@ func bindFlags(cmd *cobra.Command, action spec.ActionSpec, binds map[string]any)
 				cmd.Flags().BoolVar(ptr, f.Name, def, f.Usage)
 			}
 
+		case "stringArray":
+			ptr, ok := target.(*[]string)
+			if !ok {
+				return fmt.Errorf("flag %s binding must be *[]string", f.Name)
+			}
+			def, ok := f.Default.([]string)
+			if !ok && f.Default != nil {
+				return fmt.Errorf("flag %s default: unsupported default type %T", f.Name, f.Default)
+			}
+			if f.Shorthand != "" {
+				cmd.Flags().StringArrayVarP(ptr, f.Name, f.Shorthand, def, f.Usage)
+			} else {
+				cmd.Flags().StringArrayVar(ptr, f.Name, def, f.Usage)
+			}
+
 		default:
 			return fmt.Errorf("unsupported flag type %q for %s", f.Type, f.Name)
 		}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"chaosblade-win/exec"
+)
+
+// statusInterval controls how often running experiments refresh their recorded status.
+const statusInterval = 2 * time.Second
+
+// reportStatus periodically stores the values returned by snapshot in the tracked
+// experiment state until ctx is canceled, so `list` can show live progress.
+func reportStatus(ctx context.Context, target, id string, snapshot func() map[string]string) {
+	go func() {
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+
+		for {
+			_ = exec.UpdateExperimentStatus(target, id, snapshot())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps accepted size suffixes to their multiplier. Units are binary
+// (1 KB = 1024 bytes) to match how the existing --size flags are interpreted.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// parseSize converts human-readable sizes such as "512MB", "2GB" or "4k" into bytes.
+// A bare number is interpreted as bytes.
+func parseSize(s string) (int64, error) {
+	v := strings.TrimSpace(s)
+	if v == "" {
+		return 0, nil
+	}
+
+	i := 0
+	for i < len(v) && (v[i] >= '0' && v[i] <= '9' || v[i] == '.') {
+		i++
+	}
+	num, unit := v[:i], strings.ToLower(strings.TrimSpace(v[i:]))
+	if num == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// parseRate converts a throughput such as "10MB/s" into bytes per second. The
+// "/s" suffix is optional.
+func parseRate(s string) (int64, error) {
+	v := strings.TrimSpace(s)
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "/s"), "/S")
+	n, err := parseSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return n, nil
+}
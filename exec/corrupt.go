@@ -0,0 +1,72 @@
+package exec
+
+import (
+	"fmt"
+	"math/rand"
+
+	"chaosblade-win/internal/packet"
+)
+
+// CorruptMode selects which part of a packet is corrupted and whether the result still
+// carries valid checksums.
+type CorruptMode string
+
+const (
+	// CorruptPayload flips a bit in the transport payload and leaves the checksum stale,
+	// so the receiving stack discards the packet and TCP retransmits it.
+	CorruptPayload CorruptMode = "payload"
+	// CorruptHeader flips a bit in the transport header (or the IP payload when there is
+	// none) with a stale checksum.
+	CorruptHeader CorruptMode = "header"
+	// CorruptChecksumValid flips a bit in the transport payload and recomputes the
+	// checksums, so the corruption is delivered to the application.
+	CorruptChecksumValid CorruptMode = "checksum-valid"
+)
+
+// ParseCorruptMode validates a corruption mode name.
+func ParseCorruptMode(s string) (CorruptMode, error) {
+	switch m := CorruptMode(s); m {
+	case CorruptPayload, CorruptHeader, CorruptChecksumValid:
+		return m, nil
+	case "":
+		return CorruptPayload, nil
+	default:
+		return "", fmt.Errorf("unknown corrupt mode %q (expected payload, header or checksum-valid)", s)
+	}
+}
+
+// corruptPacket flips one random bit of pkt according to mode and reports whether it
+// did; packets with nothing to corrupt (such as a pure ACK in payload modes) are left
+// alone. Checksums are first made correct for the original bytes, and the address is
+// marked as carrying valid checksums, so checksum offload cannot repair a stale-mode
+// corruption on the way out.
+func corruptPacket(pkt []byte, addr *PacketAddress, mode CorruptMode, rng *rand.Rand) bool {
+	p, err := packet.Decode(pkt)
+	if err != nil {
+		return false
+	}
+
+	var region []byte
+	switch mode {
+	case CorruptHeader:
+		if p.TransportOffset >= 0 && p.PayloadOffset > p.TransportOffset {
+			region = p.Data[p.TransportOffset:p.PayloadOffset]
+		} else {
+			region = p.Payload()
+		}
+	default:
+		region = p.Payload()
+	}
+	if len(region) == 0 {
+		return false
+	}
+
+	p.RecomputeChecksums()
+	bit := rng.Intn(len(region) * 8)
+	region[bit/8] ^= 1 << (bit % 8)
+	if mode == CorruptChecksumValid {
+		p.RecomputeChecksums()
+	}
+	addr.ChecksumsValid = true
+	return true
+}
@@ -0,0 +1,126 @@
+package exec
+
+import (
+	"context"
+	"encoding/binary"
+	"math/bits"
+	"testing"
+
+	"chaosblade-win/internal/packet"
+)
+
+// testACKPacket builds an outbound IPv4 TCP pure ACK, with no payload, whose IP ID is id.
+func testACKPacket(t testing.TB, id uint16) []byte {
+	t.Helper()
+	b := make([]byte, 40)
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:], 40)
+	binary.BigEndian.PutUint16(b[4:], id)
+	b[8], b[9] = 64, byte(packet.ProtoTCP)
+	copy(b[12:16], []byte{10, 0, 0, 1})
+	copy(b[16:20], []byte{10, 0, 0, 2})
+	binary.BigEndian.PutUint16(b[20:], 50000)
+	binary.BigEndian.PutUint16(b[22:], 443)
+	b[32], b[33] = 5<<4, byte(packet.TCPAck)
+	p, err := packet.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RecomputeChecksums()
+	return b
+}
+
+// bitDiff counts the bits that differ between a and b, which must be the same length,
+// skipping the bytes in [skipFrom, skipTo).
+func bitDiff(a, b []byte, skipFrom, skipTo int) (n, at int) {
+	at = -1
+	for i := range a {
+		if i >= skipFrom && i < skipTo {
+			continue
+		}
+		if d := bits.OnesCount8(a[i] ^ b[i]); d > 0 {
+			n += d
+			at = i
+		}
+	}
+	return n, at
+}
+
+func TestCorruptModes(t *testing.T) {
+	const (
+		count = 50
+		size  = 100
+		udp   = 20 // transport header offset
+		data  = 28 // payload offset
+	)
+	tests := []struct {
+		mode          CorruptMode
+		from, to      int  // where the flipped bit must be
+		wantChecksums bool // whether the corrupted packet still verifies
+	}{
+		{CorruptPayload, data, size, false},
+		{CorruptHeader, udp, data, false},
+		{CorruptChecksumValid, data, size, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			r := NewNetworkDelayRunner(0, 0, 0, "", 0)
+			r.CorruptPercent = 100
+			r.CorruptMode = tt.mode
+			r.Seed = 5
+			sent := runMemory(t, r, count, size)
+
+			if len(sent) != count || r.Stats().Corrupted != count {
+				t.Fatalf("sent %d, stats = %+v; want all %d corrupted", len(sent), r.Stats(), count)
+			}
+			for _, p := range sent {
+				id := packetID(p.Data)
+				orig := testUDPPacket(t, id, size)
+				// A checksum-valid corruption also rewrites the UDP checksum.
+				skip := 0
+				if tt.mode == CorruptChecksumValid {
+					skip = udp + 6
+				}
+				n, at := bitDiff(orig, p.Data, skip, skip+2)
+				if n != 1 || at < tt.from || at >= tt.to {
+					t.Errorf("packet %d: %d bits differ, last at byte %d; want 1 in [%d, %d)", id, n, at, tt.from, tt.to)
+				}
+				if !p.Addr.ChecksumsValid {
+					t.Errorf("packet %d not marked as carrying final checksums", id)
+				}
+				d, err := packet.Decode(p.Data)
+				if valid := err == nil && d.ChecksumsValid(); valid != tt.wantChecksums {
+					t.Errorf("packet %d: checksums valid = %v, want %v", id, valid, tt.wantChecksums)
+				}
+			}
+		})
+	}
+}
+
+func TestCorruptSkipsPureACKs(t *testing.T) {
+	for _, mode := range []CorruptMode{CorruptPayload, CorruptChecksumValid} {
+		t.Run(string(mode), func(t *testing.T) {
+			dev := NewMemoryPacketDevice()
+			for i := 0; i < 20; i++ {
+				dev.Inject(testACKPacket(t, uint16(i)), PacketAddress{Outbound: true})
+			}
+			dev.EndInput()
+			r := NewNetworkDelayRunner(0, 0, 0, "outbound and tcp", 0)
+			r.CorruptPercent = 100
+			r.CorruptMode = mode
+			r.Device = dev
+			if err := r.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := r.Stats(); s.Sent != 20 || s.Corrupted != 0 {
+				t.Errorf("stats = %+v, want 20 sent and none corrupted", s)
+			}
+			for _, p := range dev.Sent() {
+				if id := packetID(p.Data); string(p.Data) != string(testACKPacket(t, id)) {
+					t.Errorf("pure ACK %d was modified", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Built-in delay distribution names.
+const (
+	DistUniform      = "uniform"
+	DistNormal       = "normal"
+	DistPareto       = "pareto"
+	DistParetoNormal = "paretonormal"
+)
+
+const (
+	// distTableSize matches netem's tables: 4096 quantiles.
+	distTableSize = 4096
+	// netemDistScale is the fixed-point scale of netem .dist files.
+	netemDistScale = 8192
+	// paretoShape is the Pareto tail index; 3 keeps the variance finite.
+	paretoShape = 3
+)
+
+// DelayDistribution shapes jitter around the base delay. Each sample is a value with
+// zero mean and unit standard deviation (except uniform, which spans [-1, 1]) that is
+// multiplied by the jitter, as in netem.
+type DelayDistribution struct {
+	Name  string
+	table []float64 // quantiles in ascending order; nil for uniform
+}
+
+var builtinDists = sync.OnceValue(func() map[string][]float64 {
+	normal := quantileTable(func(u float64) float64 { return math.Sqrt2 * math.Erfinv(2*u-1) })
+	// Sampling quantiles cuts off the far Pareto tail, which alone loses ~6% of its
+	// standard deviation, so the table is rescaled.
+	pareto := standardize(quantileTable(paretoQuantile))
+
+	// paretonormal is the netem mix of a quarter normal and three quarters Pareto,
+	// built from the quantiles of the sum over a grid of independent draws.
+	const grid = 256
+	sums := make([]float64, 0, grid*grid)
+	for i := range grid {
+		for j := range grid {
+			n := normal[(2*i+1)*distTableSize/(2*grid)]
+			p := pareto[(2*j+1)*distTableSize/(2*grid)]
+			sums = append(sums, 0.25*n+0.75*p)
+		}
+	}
+	slices.Sort(sums)
+	mixed := make([]float64, distTableSize)
+	for i := range mixed {
+		mixed[i] = sums[(2*i+1)*len(sums)/(2*distTableSize)]
+	}
+	return map[string][]float64{DistNormal: normal, DistPareto: pareto, DistParetoNormal: standardize(mixed)}
+})
+
+// quantileTable evaluates inverse CDF q at the midpoints of distTableSize bins.
+func quantileTable(q func(u float64) float64) []float64 {
+	t := make([]float64, distTableSize)
+	for i := range t {
+		t[i] = q((float64(i) + 0.5) / distTableSize)
+	}
+	return t
+}
+
+// paretoQuantile is the Pareto(1, paretoShape) quantile shifted and scaled to zero mean
+// and unit standard deviation, leaving a long right tail.
+func paretoQuantile(u float64) float64 {
+	const a float64 = paretoShape
+	mean := a / (a - 1)
+	std := math.Sqrt(a/(a-2)) / (a - 1)
+	return (math.Pow(1-u, -1/a) - mean) / std
+}
+
+// standardize rescales t to zero mean and unit standard deviation.
+func standardize(t []float64) []float64 {
+	var sum, sq float64
+	for _, v := range t {
+		sum += v
+	}
+	mean := sum / float64(len(t))
+	for _, v := range t {
+		sq += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(sq / float64(len(t)))
+	for i := range t {
+		t[i] = (t[i] - mean) / std
+	}
+	return t
+}
+
+// BuiltinDelayDistribution returns the named built-in distribution.
+func BuiltinDelayDistribution(name string) (*DelayDistribution, error) {
+	switch name {
+	case DistUniform, "":
+		return &DelayDistribution{Name: DistUniform}, nil
+	case DistNormal, DistPareto, DistParetoNormal:
+		return &DelayDistribution{Name: name, table: builtinDists()[name]}, nil
+	default:
+		return nil, fmt.Errorf("unknown delay distribution %q (expected uniform, normal, pareto or paretonormal)", name)
+	}
+}
+
+// LoadDelayDistribution reads a custom distribution table in netem's .dist format:
+// whitespace-separated values scaled by 8192, with '#' comments. Values are the
+// distribution's quantiles and are sorted on load.
+func LoadDelayDistribution(path string) (*DelayDistribution, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var table []float64
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		for _, field := range strings.Fields(text) {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: bad value %q", path, line, field)
+			}
+			table = append(table, v/netemDistScale)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("%s: distribution table is empty", path)
+	}
+	slices.Sort(table)
+	return &DelayDistribution{Name: path, table: table}, nil
+}
+
+// delaySampler draws per-packet delays of base + jitter*X, where X follows dist and
+// successive draws are correlated like netem: the uniform variate behind each sample is
+// mixed with the previous one by corr.
+type delaySampler struct {
+	base, jitter time.Duration
+	dist         *DelayDistribution
+	corr         float64
+	last         float64
+}
+
+func newDelaySampler(base, jitter time.Duration, dist *DelayDistribution, corr float64) *delaySampler {
+	if dist == nil {
+		dist = &DelayDistribution{Name: DistUniform}
+	}
+	return &delaySampler{base: base, jitter: jitter, dist: dist, corr: corr}
+}
+
+// next returns the delay for the next packet, never negative.
+func (s *delaySampler) next(rng *rand.Rand) time.Duration {
+	if s.jitter <= 0 {
+		return max(s.base, 0)
+	}
+	u := rng.Float64()
+	if s.corr > 0 {
+		u = (1-s.corr)*u + s.corr*s.last
+	}
+	s.last = u
+
+	var x float64
+	if s.dist.table == nil {
+		x = 2*u - 1
+	} else {
+		x = s.dist.table[min(int(u*float64(len(s.dist.table))), len(s.dist.table)-1)]
+	}
+	return max(s.base+time.Duration(x*float64(s.jitter)), 0)
+}
@@ -0,0 +1,153 @@
+package exec
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeDist(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.dist")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDelayDistribution(t *testing.T) {
+	path := writeDist(t, "# netem table\n8192 -8192\t0 # inline comment\n\n  4096\n-16384 # last\n")
+	d, err := LoadDelayDistribution(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{-2, -1, 0, 0.5, 1}; !slices.Equal(d.table, want) {
+		t.Errorf("table = %v, want %v", d.table, want)
+	}
+	if d.Name != path {
+		t.Errorf("Name = %q, want %q", d.Name, path)
+	}
+}
+
+func TestLoadDelayDistributionErrors(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"bad value", "# header\n1 2 3\n4 x5 6\n", `:3: bad value "x5"`},
+		{"empty", "", "distribution table is empty"},
+		{"only comments", "# nothing\n\n   # here\n", "distribution table is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadDelayDistribution(writeDist(t, tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := LoadDelayDistribution(filepath.Join(t.TempDir(), "missing.dist")); !os.IsNotExist(err) {
+		t.Errorf("missing file: error = %v", err)
+	}
+}
+
+func TestBuiltinDistributionMoments(t *testing.T) {
+	for _, name := range []string{DistNormal, DistPareto, DistParetoNormal} {
+		d, err := BuiltinDelayDistribution(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d.table) != distTableSize || !slices.IsSorted(d.table) {
+			t.Fatalf("%s: table of %d values, sorted = %v", name, len(d.table), slices.IsSorted(d.table))
+		}
+		var sum, sq float64
+		for _, v := range d.table {
+			sum += v
+		}
+		mean := sum / float64(len(d.table))
+		for _, v := range d.table {
+			sq += (v - mean) * (v - mean)
+		}
+		sd := math.Sqrt(sq / float64(len(d.table)))
+		if math.Abs(mean) > 0.01 || math.Abs(sd-1) > 0.01 {
+			t.Errorf("%s: mean %.4f, sd %.4f; want 0 and 1", name, mean, sd)
+		}
+	}
+	if _, err := BuiltinDelayDistribution("lognormal"); err == nil {
+		t.Error("unknown distribution accepted")
+	}
+}
+
+func TestDelaySamplerNeverNegative(t *testing.T) {
+	const base, jitter = 10 * time.Millisecond, 50 * time.Millisecond
+	for _, name := range []string{DistUniform, DistNormal, DistPareto, DistParetoNormal} {
+		d, err := BuiltinDelayDistribution(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newDelaySampler(base, jitter, d, 0.5)
+		rng := rand.New(rand.NewSource(1))
+		clamped := 0
+		for i := 0; i < 100000; i++ {
+			v := s.next(rng)
+			if v < 0 {
+				t.Fatalf("%s: negative delay %v", name, v)
+			}
+			if v == 0 {
+				clamped++
+			}
+		}
+		if clamped == 0 {
+			t.Errorf("%s: jitter of 5x the delay never reached zero", name)
+		}
+	}
+	if v := newDelaySampler(-time.Second, 0, nil, 0).next(rand.New(rand.NewSource(1))); v != 0 {
+		t.Errorf("negative base without jitter gave %v", v)
+	}
+}
+
+// lagCorrelation is the correlation between successive samples.
+func lagCorrelation(xs []float64) float64 {
+	var mean float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	var num, den float64
+	for i, x := range xs {
+		den += (x - mean) * (x - mean)
+		if i > 0 {
+			num += (x - mean) * (xs[i-1] - mean)
+		}
+	}
+	return num / den
+}
+
+func TestDelaySamplerCorrelation(t *testing.T) {
+	d, err := BuiltinDelayDistribution(DistNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		corr     float64
+		min, max float64
+	}{
+		{0, -0.02, 0.02},
+		{0.5, 0.4, 0.9},
+		{0.9, 0.85, 1},
+	} {
+		s := newDelaySampler(time.Second, 100*time.Millisecond, d, tt.corr)
+		rng := rand.New(rand.NewSource(2))
+		xs := make([]float64, 50000)
+		for i := range xs {
+			xs[i] = float64(s.next(rng))
+		}
+		if c := lagCorrelation(xs); c < tt.min || c > tt.max {
+			t.Errorf("corr %.1f: successive samples correlate %.3f, want [%.2f, %.2f]", tt.corr, c, tt.min, tt.max)
+		}
+	}
+}
@@ -2,14 +2,72 @@ package exec
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"math/rand/v2"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+const (
+	// diskTopUpInterval is how often a free-space target fill re-checks the volume while holding.
+	diskTopUpInterval = 5 * time.Second
+	// diskFillStep bounds how much is written between free-space re-checks.
+	diskFillStep = 256 << 20
+)
+
+// DiskFillMethod selects how a DiskFillRunner claims space on the volume.
+type DiskFillMethod string
+
+const (
+	// DiskFillWrite writes filler data chunk by chunk.
+	DiskFillWrite DiskFillMethod = "write"
+	// DiskFillFallocate reserves blocks with the platform preallocation call
+	// (fallocate on Linux, SetEndOfFile/SetFileValidData on Windows).
+	DiskFillFallocate DiskFillMethod = "fallocate"
+	// DiskFillTruncate extends the file length only; filesystems that create sparse
+	// files this way fail allocation verification.
+	DiskFillTruncate DiskFillMethod = "truncate"
+)
+
+// DiskFillContent selects the data a write fill puts on disk.
+type DiskFillContent string
+
+const (
+	// DiskFillZero writes zeros, which compression, dedup and thin provisioning collapse.
+	DiskFillZero DiskFillContent = "zero"
+	// DiskFillRandom repeats one pseudo-random buffer: incompressible, but identical
+	// chunks can still be deduplicated.
+	DiskFillRandom DiskFillContent = "random"
+	// DiskFillIncompressible regenerates pseudo-random data for every chunk so neither
+	// compression nor dedup can reclaim it.
+	DiskFillIncompressible DiskFillContent = "incompressible"
 )
 
+// ErrSparseFill indicates the fill file was extended without its blocks being allocated.
+var ErrSparseFill = errors.New("fill file is sparse; blocks were not allocated")
+
 // DiskFillRunner writes data to a file until a target size, then holds it until cancellation.
 type DiskFillRunner struct {
 	path      string
 	sizeBytes int64
+	freeLeft  int64 // when >0, fill until the volume has this many bytes free
+	method    DiskFillMethod
+	content   DiskFillContent
+	limiter   *tokenBucket // paces DiskFillWrite when a rate is set
+
+	written     atomic.Int64
+	filled      chan struct{}
+	fillOnce    sync.Once
+	fillWarning atomic.Value // error describing a fill the volume did not account for
+
+	artifactTracker
 }
 
 // NewDiskFillRunner builds a DiskFillRunner for a specific byte size.
@@ -17,68 +75,302 @@ func NewDiskFillRunner(path string, sizeBytes int64) *DiskFillRunner {
 	if sizeBytes < 1 {
 		sizeBytes = 1
 	}
-	return &DiskFillRunner{path: path, sizeBytes: sizeBytes}
+	return &DiskFillRunner{path: path, sizeBytes: sizeBytes, method: DiskFillWrite, content: DiskFillZero, filled: make(chan struct{})}
 }
 
-// Run fills the target file and removes it when the context is canceled.
-func (r *DiskFillRunner) Run(ctx context.Context) error {
-	targetPath := r.path
-	if targetPath == "" {
-		f, err := os.CreateTemp("", "chaosblade-disk-*.tmp")
-		if err != nil {
-			return err
-		}
-		targetPath = f.Name()
-		f.Close()
+// NewDiskFillFreeLeftRunner builds a DiskFillRunner that writes until the volume holding
+// path has freeLeft bytes available, and tops up if other processes free space later.
+func NewDiskFillFreeLeftRunner(path string, freeLeft int64) *DiskFillRunner {
+	return &DiskFillRunner{path: path, freeLeft: max(freeLeft, 0), sizeBytes: 1, method: DiskFillWrite, content: DiskFillZero, filled: make(chan struct{})}
+}
+
+// SetMethod selects how space is claimed; the default is DiskFillWrite.
+func (r *DiskFillRunner) SetMethod(method DiskFillMethod) {
+	r.method = method
+}
+
+// SetContent selects the data written by DiskFillWrite; the default is DiskFillZero.
+func (r *DiskFillRunner) SetContent(content DiskFillContent) {
+	r.content = content
+}
+
+// SetRate limits DiskFillWrite to bytesPerSec with a token bucket so the file grows
+// steadily instead of as fast as the disk allows; 0 removes the limit.
+func (r *DiskFillRunner) SetRate(bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		r.limiter = nil
+		return
+	}
+	r.limiter = newTokenBucket(bytesPerSec, fillChunkFor(bytesPerSec))
+}
+
+// Filled is closed once the initial fill has completed and been verified.
+func (r *DiskFillRunner) Filled() <-chan struct{} {
+	return r.filled
+}
+
+// FillWarning reports, after Filled, when the volume's free space did not drop by the
+// amount written (for example because compression or dedup reclaimed it), or nil.
+func (r *DiskFillRunner) FillWarning() error {
+	if err, ok := r.fillWarning.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// WrittenBytes reports how many bytes the runner has written so far.
+func (r *DiskFillRunner) WrittenBytes() int64 {
+	return r.written.Load()
+}
+
+// Path returns the file or directory the runner fills ("" means the temp directory).
+func (r *DiskFillRunner) Path() string {
+	return r.path
+}
+
+// RunDiskFills runs several fills concurrently until ctx ends. When one fails the
+// others are stopped so every volume is released together, and the errors are joined.
+func RunDiskFills(ctx context.Context, runners ...*DiskFillRunner) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(runners))
+	var wg sync.WaitGroup
+	for i, r := range runners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.Run(runCtx); err != nil && !errors.Is(err, context.Canceled) {
+				errs[i] = fmt.Errorf("fill %s: %w", r.path, err)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
+	return ctx.Err()
+}
 
-	f, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+// Run fills the target file and removes it when the context is canceled.
+func (r *DiskFillRunner) Run(ctx context.Context) error {
+	f, err := createFillFile(r.path)
 	if err != nil {
 		return err
 	}
+	targetPath := f.Name()
 	defer func() {
 		f.Close()
 		os.Remove(targetPath)
 	}()
+	if err := r.recordArtifact(targetPath); err != nil {
+		return err
+	}
 
-	buf := make([]byte, 1024*1024)
-	var written int64
+	if r.freeLeft > 0 {
+		return r.holdFreeLeft(ctx, f)
+	}
+
+	usageDir := filepath.Dir(targetPath)
+	before, beforeErr := disk.Usage(usageDir)
+
+	if err := r.extend(ctx, f, r.sizeBytes); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if beforeErr == nil {
+		if after, err := disk.Usage(usageDir); err == nil {
+			r.verifyFreeDrop(before.Free, after.Free)
+		}
+	}
+	r.fillOnce.Do(func() { close(r.filled) })
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// holdFreeLeft appends to f until the volume reaches the free-space target, then keeps
+// re-checking and tops up whenever free space grows back above it.
+func (r *DiskFillRunner) holdFreeLeft(ctx context.Context, f *os.File) error {
+	usageDir := filepath.Dir(f.Name())
+	ticker := time.NewTicker(diskTopUpInterval)
+	defer ticker.Stop()
+
+	for {
+		// Write in bounded steps and re-read usage between them so concurrent
+		// writers on the same volume are accounted for.
+		for {
+			stats, err := disk.Usage(usageDir)
+			if err != nil {
+				return err
+			}
+			need := int64(stats.Free) - r.freeLeft
+			if need < diskFillStep/16 {
+				r.fillOnce.Do(func() { close(r.filled) })
+				break
+			}
+			if err := r.extend(ctx, f, min(need, diskFillStep)); err != nil {
+				return err
+			}
+			if err := f.Sync(); err != nil {
+				return err
+			}
+		}
 
-	for written < r.sizeBytes {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// verifyFreeDrop records a warning when free space fell by clearly less than was written.
+// Writers on the same volume only make the drop larger, so they do not cause warnings.
+func (r *DiskFillRunner) verifyFreeDrop(before, after uint64) {
+	written := r.written.Load()
+	dropped := int64(before) - int64(after)
+	if dropped >= written-written/10 {
+		return
+	}
+	r.fillWarning.Store(fmt.Errorf("wrote %d MB but free space dropped by only %d MB; the volume may compress, deduplicate or thin-provision the data (try --content incompressible)", written>>20, max(dropped, 0)>>20))
+}
+
+// extend grows f by n bytes using the configured method.
+func (r *DiskFillRunner) extend(ctx context.Context, f *os.File, n int64) error {
+	if r.method == DiskFillWrite || r.method == "" {
+		_, err := writeFill(ctx, f, n, fillOptions{content: r.content, limiter: r.limiter, progress: &r.written})
+		return err
+	}
+
+	size := r.written.Load() + n
+	var err error
+	if r.method == DiskFillFallocate {
+		err = osPreallocate(f, size)
+	} else {
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %d bytes: %w", r.method, size, err)
+	}
+	if err := verifyAllocated(f, size); err != nil {
+		return err
+	}
+	r.written.Store(size)
+	return nil
+}
+
+// verifyAllocated checks that the filesystem really reserved blocks for size bytes of f
+// rather than recording a sparse length. Platforms that cannot report allocation pass.
+func verifyAllocated(f *os.File, size int64) error {
+	const slack = 1 << 20 // tolerate cluster rounding and metadata accounting
+	allocated, err := osAllocatedBytes(f)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if allocated+slack < size {
+		return fmt.Errorf("%w: %d of %d bytes allocated; use --method write or fallocate", ErrSparseFill, allocated, size)
+	}
+	return nil
+}
+
+// createFillFile opens path for writing. An empty path creates a temporary file in the
+// system temp directory, and an existing directory one inside it. Callers own removal
+// of the returned file.
+func createFillFile(path string) (*os.File, error) {
+	if path == "" {
+		return os.CreateTemp("", "chaosblade-disk-*.tmp")
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return os.CreateTemp(path, "chaosblade-disk-*.tmp")
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+}
+
+// fillOptions controls how writeFill produces and paces its data.
+type fillOptions struct {
+	content  DiskFillContent
+	limiter  *tokenBucket  // when non-nil, each chunk waits for tokens before it is written
+	progress *atomic.Int64 // when non-nil, each chunk is added as it lands
+}
+
+// fillChunkFor sizes write chunks so a rate-limited fill advances in small, even steps
+// (about 20 per second) rather than one large write followed by a long pause.
+func fillChunkFor(bytesPerSec int64) int64 {
+	return min(max(bytesPerSec/20, 4<<10), 1<<20)
+}
+
+// fillRandom fills b with pseudo-random bytes from rng.
+func fillRandom(rng *rand.Rand, b []byte) {
+	for ; len(b) >= 8; b = b[8:] {
+		binary.LittleEndian.PutUint64(b, rng.Uint64())
+	}
+	if len(b) > 0 {
+		var tail [8]byte
+		binary.LittleEndian.PutUint64(tail[:], rng.Uint64())
+		copy(b, tail[:])
+	}
+}
+
+// writeFill writes sizeBytes of filler data to f, stopping early if ctx is canceled.
+// It returns the number of bytes written.
+func writeFill(ctx context.Context, f *os.File, sizeBytes int64, opts fillOptions) (int64, error) {
+	chunkSize := int64(1 << 20)
+	if opts.limiter != nil {
+		chunkSize = int64(opts.limiter.burst)
+	}
+	buf := make([]byte, chunkSize)
+	var written int64
+
+	var rng *rand.Rand
+	if opts.content == DiskFillRandom || opts.content == DiskFillIncompressible {
+		rng = rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))
+		fillRandom(rng, buf)
+	}
+
+	for written < sizeBytes {
+		select {
+		case <-ctx.Done():
+			return written, ctx.Err()
 		default:
 		}
 
-		remaining := r.sizeBytes - written
+		if opts.content == DiskFillIncompressible && written > 0 {
+			fillRandom(rng, buf)
+		}
+
+		remaining := sizeBytes - written
 		chunk := len(buf)
 		if int64(chunk) > remaining {
 			chunk = int(remaining)
 		}
 
+		if opts.limiter != nil {
+			if err := opts.limiter.wait(ctx, int64(chunk)); err != nil {
+				return written, err
+			}
+		}
+
 		n, err := f.Write(buf[:chunk])
+		written += int64(n)
+		if opts.progress != nil {
+			opts.progress.Add(int64(n))
+		}
 		if err != nil {
-			return err
+			return written, err
 		}
-		written += int64(n)
-	}
-
-	if err := f.Sync(); err != nil {
-		return err
 	}
-
-	<-ctx.Done()
-	return ctx.Err()
+	return written, nil
 }
 
 // ErrDiskPathRequired indicates a missing path when required.
 var ErrDiskPathRequired = errors.New("disk path required")
-
-// DiskIOPSRunner placeholder for future IO stress.
-type DiskIOPSRunner struct{}
-
-func (DiskIOPSRunner) Run(ctx context.Context) error {
-	<-ctx.Done()
-	return ctx.Err()
-}
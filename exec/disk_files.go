@@ -0,0 +1,112 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// DiskFilesRunner creates many small files under a root directory to exhaust inodes or
+// MFT records, holds them, and removes the tree when the context is canceled.
+type DiskFilesRunner struct {
+	root    string
+	count   int64
+	size    int64
+	perDir  int64 // when >0, nest files so no directory holds more than perDir entries
+	created atomic.Int64
+	stopErr atomic.Value // error that ended creation early, if any
+}
+
+// NewDiskFilesRunner builds a DiskFilesRunner that creates count files of size bytes
+// under root. root is owned by the runner and removed on exit.
+func NewDiskFilesRunner(root string, count, size, perDir int64) *DiskFilesRunner {
+	if count < 1 {
+		count = 1
+	}
+	return &DiskFilesRunner{root: root, count: count, size: max(size, 0), perDir: max(perDir, 0)}
+}
+
+// CreatedFiles reports how many files exist so far.
+func (r *DiskFilesRunner) CreatedFiles() int64 {
+	return r.created.Load()
+}
+
+// StopErr returns the error that ended file creation early (for example the volume
+// running out of inodes), or nil when every file was created.
+func (r *DiskFilesRunner) StopErr() error {
+	if err, ok := r.stopErr.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Run creates the files, holds them until cancellation, then removes the root.
+// Hitting a filesystem limit after at least one file was created is the point of
+// the experiment, so creation stops there and the files are held.
+func (r *DiskFilesRunner) Run(ctx context.Context) error {
+	defer os.RemoveAll(r.root)
+
+	depth := r.nestingDepth()
+	content := make([]byte, r.size)
+	made := make(map[string]bool)
+
+	for i := int64(0); i < r.count; i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		dir, name := r.pathFor(i, depth)
+		if dir != r.root && !made[dir] {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				if r.created.Load() == 0 {
+					return err
+				}
+				r.stopErr.Store(err)
+				break
+			}
+			made[dir] = true
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			if r.created.Load() == 0 {
+				return err
+			}
+			r.stopErr.Store(err)
+			break
+		}
+		r.created.Add(1)
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// nestingDepth returns how many directory levels are needed so that no directory
+// holds more than perDir entries.
+func (r *DiskFilesRunner) nestingDepth() int {
+	if r.perDir < 2 {
+		return 0
+	}
+	depth := 0
+	for capacity := r.perDir; capacity < r.count; capacity *= r.perDir {
+		depth++
+	}
+	return depth
+}
+
+// pathFor maps file index i to its directory and file name. With nesting, the index is
+// written in base perDir: leading digits name the directories, the last the file.
+func (r *DiskFilesRunner) pathFor(i int64, depth int) (string, string) {
+	if depth == 0 {
+		return r.root, fmt.Sprintf("f%d", i)
+	}
+	parts := make([]string, depth)
+	n := i / r.perDir
+	for level := depth - 1; level >= 0; level-- {
+		parts[level] = fmt.Sprintf("d%d", n%r.perDir)
+		n /= r.perDir
+	}
+	return filepath.Join(append([]string{r.root}, parts...)...), fmt.Sprintf("f%d", i%r.perDir)
+}
@@ -0,0 +1,239 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// directIOAlign is the buffer/offset alignment required for unbuffered IO. 4 KiB covers
+// both 512e and 4Kn sector sizes.
+const directIOAlign = 4096
+
+// DiskIOPSRunner generates sustained read/write IO against a working file until the
+// context is canceled.
+type DiskIOPSRunner struct {
+	Dir         string // directory or volume root for the working file (temp dir if empty)
+	Read        bool
+	Write       bool
+	ReadPercent int // share of reads when both Read and Write are set
+	BlockSize   int
+	IODepth     int
+	Sequential  bool
+	FileSize    int64
+	Direct      bool // bypass the OS cache (O_DIRECT / FILE_FLAG_NO_BUFFERING) where supported
+
+	startNanos atomic.Int64
+	readOps    atomic.Int64
+	writeOps   atomic.Int64
+	readBytes  atomic.Int64
+	writeBytes atomic.Int64
+
+	artifactTracker
+}
+
+// DiskIOStats summarizes the IO a DiskIOPSRunner has completed.
+type DiskIOStats struct {
+	ReadOps    int64
+	WriteOps   int64
+	ReadBytes  int64
+	WriteBytes int64
+	Elapsed    time.Duration
+}
+
+// IOPS returns the average operations per second since the runner started.
+func (s DiskIOStats) IOPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.ReadOps+s.WriteOps) / s.Elapsed.Seconds()
+}
+
+// Throughput returns the average bytes per second since the runner started.
+func (s DiskIOStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.ReadBytes+s.WriteBytes) / s.Elapsed.Seconds()
+}
+
+// NewDiskIOPSRunner builds a DiskIOPSRunner with a 1 GB working file and direct IO enabled.
+func NewDiskIOPSRunner(dir string, read, write bool, blockSize, ioDepth int, sequential bool) *DiskIOPSRunner {
+	if blockSize < 512 {
+		blockSize = 512
+	}
+	if ioDepth < 1 {
+		ioDepth = 1
+	}
+	return &DiskIOPSRunner{
+		Dir:         dir,
+		Read:        read,
+		Write:       write,
+		ReadPercent: 50,
+		BlockSize:   blockSize,
+		IODepth:     ioDepth,
+		Sequential:  sequential,
+		FileSize:    1 << 30,
+		Direct:      true,
+	}
+}
+
+// Stats returns a snapshot of completed IO.
+func (r *DiskIOPSRunner) Stats() DiskIOStats {
+	var elapsed time.Duration
+	if start := r.startNanos.Load(); start != 0 {
+		elapsed = time.Since(time.Unix(0, start))
+	}
+	return DiskIOStats{
+		ReadOps:    r.readOps.Load(),
+		WriteOps:   r.writeOps.Load(),
+		ReadBytes:  r.readBytes.Load(),
+		WriteBytes: r.writeBytes.Load(),
+		Elapsed:    elapsed,
+	}
+}
+
+// Run prepares the working file, drives IODepth concurrent workers, and removes the
+// file when the context is canceled.
+func (r *DiskIOPSRunner) Run(ctx context.Context) error {
+	if !r.Read && !r.Write {
+		return fmt.Errorf("disk burn requires read and/or write")
+	}
+	if r.Direct && r.BlockSize%directIOAlign != 0 {
+		return fmt.Errorf("direct IO requires a block size that is a multiple of %d bytes", directIOAlign)
+	}
+
+	blocks := r.FileSize / int64(r.BlockSize)
+	if blocks < 1 {
+		return fmt.Errorf("working file size %d is smaller than block size %d", r.FileSize, r.BlockSize)
+	}
+
+	// Lay down real data first so reads hit the device instead of sparse holes.
+	prep, err := os.CreateTemp(r.Dir, "chaosblade-burn-*.dat")
+	if err != nil {
+		return err
+	}
+	workPath := prep.Name()
+	defer os.Remove(workPath)
+	if err := r.recordArtifact(workPath); err != nil {
+		prep.Close()
+		return err
+	}
+
+	if _, err := writeFill(ctx, prep, blocks*int64(r.BlockSize), fillOptions{}); err != nil {
+		prep.Close()
+		return err
+	}
+	if err := prep.Sync(); err != nil {
+		prep.Close()
+		return err
+	}
+	prep.Close()
+
+	// Each worker gets its own handle: positional IO on one shared *os.File is
+	// serialized by its internal lock on Windows, which would cap the queue depth at 1.
+	files := make([]*os.File, r.IODepth)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i := range files {
+		if r.Direct {
+			files[i], err = openDirect(workPath)
+			if err != nil && i == 0 {
+				// Some filesystems, tmpfs among them, reject O_DIRECT with EINVAL.
+				fmt.Fprintf(os.Stderr, "warning: direct IO not supported for %s (%v); using cached IO\n", workPath, err)
+				r.Direct = false
+			}
+		}
+		if !r.Direct {
+			files[i], err = os.OpenFile(workPath, os.O_RDWR, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var seq atomic.Int64
+	var wg sync.WaitGroup
+	errCh := make(chan error, r.IODepth)
+	r.startNanos.Store(time.Now().UnixNano())
+
+	for i := 0; i < r.IODepth; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			f := files[worker]
+			rng := rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), uint64(worker)))
+			buf := alignedBuffer(r.BlockSize)
+			for j := range buf {
+				buf[j] = byte(rng.Uint32())
+			}
+
+			for workCtx.Err() == nil {
+				var block int64
+				if r.Sequential {
+					block = (seq.Add(1) - 1) % blocks
+				} else {
+					block = rng.Int64N(blocks)
+				}
+				off := block * int64(r.BlockSize)
+
+				doRead := r.Read
+				if r.Read && r.Write {
+					doRead = rng.IntN(100) < r.ReadPercent
+				}
+
+				if doRead {
+					n, err := f.ReadAt(buf, off)
+					if err != nil {
+						errCh <- err
+						return
+					}
+					r.readOps.Add(1)
+					r.readBytes.Add(int64(n))
+				} else {
+					n, err := f.WriteAt(buf, off)
+					if err != nil {
+						errCh <- err
+						return
+					}
+					r.writeOps.Add(1)
+					r.writeBytes.Add(int64(n))
+				}
+			}
+		}(i)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		runErr = ctx.Err()
+	case runErr = <-errCh:
+	}
+	// Workers stop at their next check; wait for them before the files are closed.
+	cancel()
+	wg.Wait()
+	return runErr
+}
+
+// alignedBuffer returns a size-byte slice whose start is aligned for direct IO.
+func alignedBuffer(size int) []byte {
+	raw := make([]byte, size+directIOAlign)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&raw[0])) % directIOAlign); rem != 0 {
+		off = directIOAlign - rem
+	}
+	return raw[off : off+size]
+}
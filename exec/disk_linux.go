@@ -0,0 +1,30 @@
+package exec
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openDirect opens path for read/write IO with O_DIRECT, bypassing the page cache.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|syscall.O_DIRECT, 0)
+}
+
+// osPreallocate reserves blocks for the first size bytes of f with fallocate.
+func osPreallocate(f *os.File, size int64) error {
+	return syscall.Fallocate(int(f.Fd()), 0, 0, size)
+}
+
+// osAllocatedBytes reports how many bytes of storage are allocated to f.
+func osAllocatedBytes(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return st.Blocks * 512, nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !windows
+
+package exec
+
+import (
+	"errors"
+	"os"
+)
+
+// openDirect falls back to buffered IO on platforms without a portable direct IO flag.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
+
+// osPreallocate is unavailable here; use the write method instead.
+func osPreallocate(f *os.File, size int64) error {
+	return errors.ErrUnsupported
+}
+
+// osAllocatedBytes cannot be determined portably on this platform.
+func osAllocatedBytes(f *os.File) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileBackup records where the original copy of a tampered file is kept.
+type FileBackup struct {
+	Original string      `json:"original"`
+	Backup   string      `json:"backup"`
+	Mode     os.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"modTime"`
+}
+
+// BackupFile copies path into the state directory of an experiment owned by the current
+// process and records the backup in its state, so destroy can restore it.
+func BackupFile(target, id, path string) (FileBackup, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return FileBackup{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return FileBackup{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return FileBackup{}, fmt.Errorf("%s is not a regular file", abs)
+	}
+
+	dir := filepath.Join(stateDirForTarget(target), id+".backup")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return FileBackup{}, err
+	}
+	b := FileBackup{
+		Original: abs,
+		Backup:   filepath.Join(dir, filepath.Base(abs)),
+		Mode:     info.Mode().Perm(),
+		ModTime:  info.ModTime(),
+	}
+	if err := copyFile(abs, b.Backup, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return FileBackup{}, fmt.Errorf("back up %s: %w", abs, err)
+	}
+
+	err = updateOwnedState(target, id, func(state *ExperimentState) error {
+		state.Backups = append(state.Backups, b)
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return FileBackup{}, err
+	}
+	return b, nil
+}
+
+// RestoreFile puts the backed-up content, permissions and modification time back at the
+// original path and removes the backup. The content is staged next to the original and
+// renamed into place so readers never see a partial file.
+func RestoreFile(b FileBackup) error {
+	staged := b.Original + ".chaosblade-restore"
+	if err := copyFile(b.Backup, staged, 0o600); err != nil {
+		return err
+	}
+	if err := os.Chmod(staged, b.Mode); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	_ = os.Chtimes(staged, b.ModTime, b.ModTime)
+	// Windows refuses to replace a read-only file, which chmod tampering may have left.
+	_ = os.Chmod(b.Original, 0o600)
+	if err := os.Rename(staged, b.Original); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	os.Remove(b.Backup)
+	os.Remove(filepath.Dir(b.Backup))
+	return nil
+}
+
+// restoreBackups restores every file recorded for a destroyed experiment. A killed
+// process may briefly keep a lock or handle on the file, so restore is retried.
+func restoreBackups(state ExperimentState) {
+	for _, b := range state.Backups {
+		var err error
+		for attempt := 0; attempt < 20; attempt++ {
+			if err = RestoreFile(b); err == nil {
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: restore %s from %s: %v\n", b.Original, b.Backup, err)
+		}
+	}
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
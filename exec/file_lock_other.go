@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package exec
+
+import "errors"
+
+func osLockFileExclusive(path string) (func(), error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// osLockFileExclusive takes a non-blocking exclusive flock on path. Locks are advisory
+// on Unix, so only applications that lock the file themselves are affected.
+func osLockFileExclusive(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return func() { f.Close() }, nil
+}
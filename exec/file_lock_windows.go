@@ -0,0 +1,31 @@
+package exec
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const lockfileExclusiveLock = 0x2
+
+var procLockFileEx = kernel32DLL.NewProc("LockFileEx")
+
+// osLockFileExclusive opens path without sharing, so other processes cannot open it
+// at all, and additionally holds a mandatory byte-range lock over the whole file.
+func osLockFileExclusive(path string) (func(), error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s exclusively: %w", path, err)
+	}
+	var ol syscall.Overlapped
+	r, _, callErr := procLockFileEx.Call(uintptr(h), lockfileExclusiveLock, 0, 0xFFFFFFFF, 0xFFFFFFFF, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		syscall.CloseHandle(h)
+		return nil, fmt.Errorf("LockFileEx %s: %w", path, callErr)
+	}
+	return func() { syscall.CloseHandle(h) }, nil
+}
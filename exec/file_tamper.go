@@ -0,0 +1,135 @@
+package exec
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"os"
+)
+
+// FileTamperAction selects how a FileTamperRunner damages its file.
+type FileTamperAction string
+
+// Supported file tamper actions.
+const (
+	FileTamperAppend   FileTamperAction = "append"
+	FileTamperTruncate FileTamperAction = "truncate"
+	FileTamperFlip     FileTamperAction = "flip"
+	FileTamperRename   FileTamperAction = "rename"
+	FileTamperLock     FileTamperAction = "lock"
+	FileTamperChmod    FileTamperAction = "chmod"
+)
+
+// FileTamperRunner damages a backed-up file, holds the damage until the context is
+// canceled, then restores the original from the backup.
+type FileTamperRunner struct {
+	Action   FileTamperAction
+	Backup   FileBackup
+	Size     int64       // bytes appended (append) or resulting length (truncate)
+	Count    int         // bytes flipped (flip)
+	RenameTo string      // destination the file is moved to (rename)
+	Mode     os.FileMode // permissions applied (chmod)
+}
+
+// NewFileTamperRunner builds a runner applying action to the file recorded in backup.
+func NewFileTamperRunner(action FileTamperAction, backup FileBackup) *FileTamperRunner {
+	return &FileTamperRunner{Action: action, Backup: backup, Size: 4096, Count: 16}
+}
+
+// Run applies the change and restores the original once the context ends.
+func (r *FileTamperRunner) Run(ctx context.Context) error {
+	var release func()
+	var err error
+	switch r.Action {
+	case FileTamperAppend:
+		err = r.appendGarbage()
+	case FileTamperTruncate:
+		err = os.Truncate(r.Backup.Original, r.Size)
+	case FileTamperFlip:
+		err = r.flipBytes()
+	case FileTamperRename:
+		err = r.renameAway()
+	case FileTamperLock:
+		release, err = osLockFileExclusive(r.Backup.Original)
+	case FileTamperChmod:
+		err = os.Chmod(r.Backup.Original, r.Mode)
+	default:
+		err = fmt.Errorf("unknown file action %q", r.Action)
+	}
+	if err != nil {
+		return errors.Join(err, r.restore())
+	}
+
+	<-ctx.Done()
+	if release != nil {
+		release()
+	}
+	if err := r.restore(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (r *FileTamperRunner) restore() error {
+	if err := RestoreFile(r.Backup); err != nil {
+		return fmt.Errorf("restore %s: %w", r.Backup.Original, err)
+	}
+	if r.Action == FileTamperRename && r.RenameTo != "" {
+		os.Remove(r.RenameTo)
+	}
+	return nil
+}
+
+func (r *FileTamperRunner) appendGarbage() error {
+	f, err := os.OpenFile(r.Backup.Original, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.CopyN(f, rand.Reader, r.Size); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// flipBytes inverts Count bytes at random offsets. Each byte is XORed with a non-zero
+// mask so it is guaranteed to change.
+func (r *FileTamperRunner) flipBytes() error {
+	f, err := os.OpenFile(r.Backup.Original, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%s is empty; nothing to flip", r.Backup.Original)
+	}
+	b := make([]byte, 1)
+	for i := 0; i < r.Count; i++ {
+		off := mrand.Int64N(info.Size())
+		if _, err := f.ReadAt(b, off); err != nil {
+			return err
+		}
+		b[0] ^= byte(1 + mrand.IntN(255))
+		if _, err := f.WriteAt(b, off); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+func (r *FileTamperRunner) renameAway() error {
+	if r.RenameTo == "" {
+		r.RenameTo = r.Backup.Original + ".chaosblade"
+	}
+	if _, err := os.Lstat(r.RenameTo); err == nil {
+		return fmt.Errorf("rename destination %s already exists", r.RenameTo)
+	}
+	return os.Rename(r.Backup.Original, r.RenameTo)
+}
@@ -0,0 +1,321 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// FSOp names a filesystem operation that fault rules can target.
+type FSOp string
+
+// Filesystem operations recognized by fault rules.
+const (
+	FSOpOpen     FSOp = "open"
+	FSOpCreate   FSOp = "create"
+	FSOpRead     FSOp = "read"
+	FSOpWrite    FSOp = "write"
+	FSOpFsync    FSOp = "fsync"
+	FSOpTruncate FSOp = "truncate"
+	FSOpStat     FSOp = "stat"
+	FSOpReaddir  FSOp = "readdir"
+	FSOpMkdir    FSOp = "mkdir"
+	FSOpRmdir    FSOp = "rmdir"
+	FSOpUnlink   FSOp = "unlink"
+	FSOpRename   FSOp = "rename"
+	// FSOpAll matches every operation.
+	FSOpAll FSOp = "*"
+)
+
+// FSMutatingOps are the operations a read-only filesystem rejects.
+var FSMutatingOps = []FSOp{FSOpCreate, FSOpWrite, FSOpTruncate, FSOpMkdir, FSOpRmdir, FSOpUnlink, FSOpRename}
+
+var fsOpNames = map[FSOp]bool{
+	FSOpOpen: true, FSOpCreate: true, FSOpRead: true, FSOpWrite: true, FSOpFsync: true,
+	FSOpTruncate: true, FSOpStat: true, FSOpReaddir: true, FSOpMkdir: true, FSOpRmdir: true,
+	FSOpUnlink: true, FSOpRename: true, FSOpAll: true,
+}
+
+// ParseFSOps parses a comma-separated operation list such as "read,write".
+func ParseFSOps(s string) ([]FSOp, error) {
+	var ops []FSOp
+	for _, part := range strings.Split(s, ",") {
+		op := FSOp(strings.ToLower(strings.TrimSpace(part)))
+		if op == "" {
+			continue
+		}
+		if op == "all" {
+			op = FSOpAll
+		}
+		if !fsOpNames[op] {
+			return nil, fmt.Errorf("unknown filesystem operation %q", part)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// ParseFSGlobs parses a comma-separated glob list such as "*.db,logs/*", rejecting
+// malformed patterns.
+func ParseFSGlobs(s string) ([]string, error) {
+	var globs []string
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g == "" {
+			continue
+		}
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+var fsErrnoNames = map[string]syscall.Errno{
+	"EIO":    syscall.EIO,
+	"ENOSPC": syscall.ENOSPC,
+	"EROFS":  syscall.EROFS,
+	"EACCES": syscall.EACCES,
+	"EPERM":  syscall.EPERM,
+	"ENOENT": syscall.ENOENT,
+	"EAGAIN": syscall.EAGAIN,
+	"EBUSY":  syscall.EBUSY,
+}
+
+// ParseFSErrno resolves an errno name such as "ENOSPC" for fault rules.
+func ParseFSErrno(name string) (syscall.Errno, error) {
+	if name == "" {
+		return 0, nil
+	}
+	errno, ok := fsErrnoNames[strings.ToUpper(name)]
+	if !ok {
+		names := make([]string, 0, len(fsErrnoNames))
+		for n := range fsErrnoNames {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return 0, fmt.Errorf("unknown error %q (expected one of %s)", name, strings.Join(names, ", "))
+	}
+	return errno, nil
+}
+
+// FSFaultRule describes faults applied to operations on matching paths.
+type FSFaultRule struct {
+	Ops       []FSOp        // operations affected; empty means all
+	Globs     []string      // slash-separated globs relative to the root; a glob without '/' matches base names
+	Errno     syscall.Errno // error returned instead of performing the operation (0 = none)
+	Latency   time.Duration // delay added before the operation
+	ShortRead int           // when >0, reads return at most this many bytes
+	Percent   float64       // chance (0-100) that a matching operation is affected
+}
+
+func (r FSFaultRule) matches(op FSOp, rel string) bool {
+	if len(r.Ops) > 0 {
+		found := false
+		for _, o := range r.Ops {
+			if o == op || o == FSOpAll {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Globs) == 0 {
+		return true
+	}
+	// Globs are checked by ParseFSGlobs, so Match cannot fail here.
+	base := path.Base(rel)
+	for _, g := range r.Globs {
+		if ok, _ := path.Match(g, rel); ok {
+			return true
+		}
+		if !strings.Contains(g, "/") {
+			if ok, _ := path.Match(g, base); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FSFault is the outcome of evaluating rules for one operation.
+type FSFault struct {
+	Errno     syscall.Errno
+	Delay     time.Duration
+	ShortRead int
+}
+
+// FSFaultInjector decides which faults apply to filesystem operations. Backends call
+// Decide for every operation they interpose on.
+type FSFaultInjector struct {
+	rules []FSFaultRule
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	injected atomic.Int64
+}
+
+// NewFSFaultInjector builds an injector for the given rules.
+func NewFSFaultInjector(rules ...FSFaultRule) *FSFaultInjector {
+	return &FSFaultInjector{
+		rules: rules,
+		rng:   rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0)),
+	}
+}
+
+// Decide evaluates the rules for op on rel (slash-separated, relative to the root).
+// Latency from every triggered rule adds up; the first error wins.
+func (i *FSFaultInjector) Decide(op FSOp, rel string) FSFault {
+	var f FSFault
+	for _, r := range i.rules {
+		if !r.matches(op, rel) || !i.roll(r.Percent) {
+			continue
+		}
+		f.Delay += r.Latency
+		if f.Errno == 0 {
+			f.Errno = r.Errno
+		}
+		if op == FSOpRead && r.ShortRead > 0 && (f.ShortRead == 0 || r.ShortRead < f.ShortRead) {
+			f.ShortRead = r.ShortRead
+		}
+	}
+	if f.Errno != 0 || f.Delay > 0 || f.ShortRead > 0 {
+		i.injected.Add(1)
+	}
+	return f
+}
+
+// Injected reports how many operations have been affected so far.
+func (i *FSFaultInjector) Injected() int64 {
+	return i.injected.Load()
+}
+
+func (i *FSFaultInjector) roll(percent float64) bool {
+	if percent >= 100 {
+		return true
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64()*100 < percent
+}
+
+// Wait sleeps for the fault delay, returning false if ctx ends first.
+func (f FSFault) Wait(ctx context.Context) bool {
+	if f.Delay <= 0 {
+		return true
+	}
+	t := time.NewTimer(f.Delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// FSMount is an active interposition created by an FSBackend.
+type FSMount interface {
+	// Unmount removes the interposition and restores direct access to the directory.
+	Unmount() error
+	// Wait blocks until the interposition ends, including when removed externally.
+	Wait()
+}
+
+// FSBackend interposes on a directory in place and routes its operations through an
+// injector. Implementations register themselves with RegisterFSBackend.
+type FSBackend interface {
+	Mount(dir string, inj *FSFaultInjector) (FSMount, error)
+}
+
+var fsBackends = map[string]FSBackend{}
+
+// ErrNoFSBackend indicates no interposing filesystem backend is available.
+var ErrNoFSBackend = errors.New("no filesystem interposition backend available on this platform")
+
+// RegisterFSBackend makes a backend selectable by name.
+func RegisterFSBackend(name string, b FSBackend) {
+	fsBackends[name] = b
+}
+
+// FSBackendNames lists registered backends in name order.
+func FSBackendNames() []string {
+	names := make([]string, 0, len(fsBackends))
+	for n := range fsBackends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fsBackendFor(name string) (FSBackend, error) {
+	if name == "" {
+		names := FSBackendNames()
+		if len(names) == 0 {
+			return nil, ErrNoFSBackend
+		}
+		name = names[0]
+	}
+	b, ok := fsBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filesystem backend %q (available: %s)", name, strings.Join(FSBackendNames(), ", "))
+	}
+	return b, nil
+}
+
+// FSFaultRunner interposes on a directory and injects faults until the context ends.
+type FSFaultRunner struct {
+	dir      string
+	backend  string
+	injector *FSFaultInjector
+}
+
+// NewFSFaultRunner builds a runner for dir using the named backend ("" picks the default).
+func NewFSFaultRunner(dir, backend string, injector *FSFaultInjector) *FSFaultRunner {
+	return &FSFaultRunner{dir: dir, backend: backend, injector: injector}
+}
+
+// Injected reports how many operations have been affected so far.
+func (r *FSFaultRunner) Injected() int64 {
+	return r.injector.Injected()
+}
+
+// Run mounts the interposition and removes it when the context is canceled.
+func (r *FSFaultRunner) Run(ctx context.Context) error {
+	b, err := fsBackendFor(r.backend)
+	if err != nil {
+		return err
+	}
+	m, err := b.Mount(r.dir, r.injector)
+	if err != nil {
+		return err
+	}
+
+	ended := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(ended)
+	}()
+
+	select {
+	case <-ctx.Done():
+		if err := m.Unmount(); err != nil {
+			return fmt.Errorf("unmount %s: %w", r.dir, err)
+		}
+		<-ended
+		return ctx.Err()
+	case <-ended:
+		return fmt.Errorf("interposed filesystem on %s was unmounted externally", r.dir)
+	}
+}
@@ -0,0 +1,340 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func init() {
+	RegisterFSBackend("fuse", fuseBackend{})
+}
+
+// fuseBackend interposes with a go-fuse loopback filesystem mounted over the target
+// directory itself, so applications keep using their configured paths.
+type fuseBackend struct{}
+
+// Mount covers dir with a passthrough FUSE filesystem. A descriptor for the real
+// directory is opened first: once the mount hides dir, /proc/self/fd/N is the only path
+// that still reaches the underlying files.
+func (fuseBackend) Mount(dir string, inj *FSFaultInjector) (FSMount, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	under, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(under.Fd()), &st); err != nil {
+		under.Close()
+		return nil, err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		under.Close()
+		return nil, fmt.Errorf("%s is not a directory", abs)
+	}
+
+	root := &fs.LoopbackRoot{
+		Path: fmt.Sprintf("/proc/self/fd/%d", under.Fd()),
+		Dev:  uint64(st.Dev),
+	}
+	rootNode := &faultNode{LoopbackNode: &fs.LoopbackNode{RootData: root}, inj: inj}
+	root.RootNode = rootNode
+
+	server, err := fs.Mount(abs, rootNode, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:     abs,
+			Name:       "chaosblade",
+			AllowOther: os.Geteuid() == 0,
+			// Mount directly when privileged; go-fuse falls back to fusermount otherwise.
+			DirectMount: true,
+		},
+	})
+	if err != nil {
+		under.Close()
+		return nil, fmt.Errorf("mount fuse over %s: %w", abs, err)
+	}
+	return &fuseMount{server: server, under: under}, nil
+}
+
+type fuseMount struct {
+	server *fuse.Server
+	under  *os.File
+}
+
+func (m *fuseMount) Unmount() error {
+	err := m.server.Unmount()
+	m.under.Close()
+	return err
+}
+
+func (m *fuseMount) Wait() {
+	m.server.Wait()
+}
+
+// unmountInterposed removes a FUSE mount left behind by a killed experiment process.
+// A lazy unmount detaches it even while applications still hold files open. Paths
+// that are no longer covered by our mount are left alone, so a destroy running after
+// a clean exit cannot detach whatever is mounted there now.
+func unmountInterposed(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	ours, err := interposedMounted(abs)
+	if err != nil || !ours {
+		return err
+	}
+	if err := syscall.Unmount(abs, syscall.MNT_DETACH); err == nil {
+		return nil
+	}
+	var lastErr error
+	for _, tool := range []string{"fusermount3", "fusermount"} {
+		out, err := exec.Command(tool, "-u", "-z", abs).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s: %v: %s", tool, err, out)
+	}
+	return lastErr
+}
+
+// interposedMounted reports whether /proc/self/mountinfo lists a FUSE mount at dir
+// whose source is dir itself, which is how fuseBackend.Mount names its mounts.
+func interposedMounted(dir string) (bool, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Format: id parent major:minor root mountpoint opts [optional...] - fstype source superopts
+		pre, post, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		fields, tail := strings.Fields(pre), strings.Fields(post)
+		if len(fields) < 5 || len(tail) < 2 {
+			continue
+		}
+		fstype := tail[0]
+		if fstype != "fuse" && !strings.HasPrefix(fstype, "fuse.") {
+			continue
+		}
+		if unescapeMountinfo(fields[4]) == dir && unescapeMountinfo(tail[1]) == dir {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// unescapeMountinfo decodes the octal escapes (\040 for space and so on) the kernel
+// uses for whitespace and backslashes in mountinfo paths.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// faultNode is a loopback node that consults the injector before each operation.
+type faultNode struct {
+	*fs.LoopbackNode
+	inj *FSFaultInjector
+}
+
+var (
+	_ fs.NodeWrapChilder = (*faultNode)(nil)
+	_ fs.NodeOpener      = (*faultNode)(nil)
+	_ fs.NodeCreater     = (*faultNode)(nil)
+	_ fs.NodeGetattrer   = (*faultNode)(nil)
+	_ fs.NodeSetattrer   = (*faultNode)(nil)
+	_ fs.NodeReaddirer   = (*faultNode)(nil)
+	_ fs.NodeMkdirer     = (*faultNode)(nil)
+	_ fs.NodeRmdirer     = (*faultNode)(nil)
+	_ fs.NodeUnlinker    = (*faultNode)(nil)
+	_ fs.NodeRenamer     = (*faultNode)(nil)
+)
+
+func (n *faultNode) WrapChild(ctx context.Context, ops fs.InodeEmbedder) fs.InodeEmbedder {
+	return &faultNode{LoopbackNode: ops.(*fs.LoopbackNode), inj: n.inj}
+}
+
+// rel returns the node path relative to the interposed root, or a child of it.
+func (n *faultNode) rel(child string) string {
+	p := n.Path(n.RootData.RootNode.EmbeddedInode())
+	if child != "" {
+		p = filepath.ToSlash(filepath.Join(p, child))
+	}
+	return p
+}
+
+// fault evaluates and applies delay for op, returning a non-zero errno to fail it.
+func (n *faultNode) fault(ctx context.Context, op FSOp, rel string) syscall.Errno {
+	f := n.inj.Decide(op, rel)
+	if !f.Wait(ctx) {
+		return syscall.EINTR
+	}
+	return f.Errno
+}
+
+func (n *faultNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	rel := n.rel("")
+	if errno := n.fault(ctx, FSOpOpen, rel); errno != 0 {
+		return nil, 0, errno
+	}
+	fh, fuseFlags, errno := n.LoopbackNode.Open(ctx, flags)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+	// Bypass the kernel page cache so every read reaches the injector; otherwise
+	// repeated reads are served from cache without the configured delay or fault.
+	return n.wrapFile(fh, rel), fuseFlags | fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *faultNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	rel := n.rel(name)
+	if errno := n.fault(ctx, FSOpCreate, rel); errno != 0 {
+		return nil, nil, 0, errno
+	}
+	inode, fh, fuseFlags, errno := n.LoopbackNode.Create(ctx, name, flags, mode, out)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	return inode, n.wrapFile(fh, rel), fuseFlags | fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *faultNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if errno := n.fault(ctx, FSOpStat, n.rel("")); errno != 0 {
+		return errno
+	}
+	if ff, ok := f.(*faultFile); ok {
+		f = ff.LoopbackFile
+	}
+	return n.LoopbackNode.Getattr(ctx, f, out)
+}
+
+func (n *faultNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if _, ok := in.GetSize(); ok {
+		if errno := n.fault(ctx, FSOpTruncate, n.rel("")); errno != 0 {
+			return errno
+		}
+	}
+	if ff, ok := f.(*faultFile); ok {
+		f = ff.LoopbackFile
+	}
+	return n.LoopbackNode.Setattr(ctx, f, in, out)
+}
+
+func (n *faultNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if errno := n.fault(ctx, FSOpReaddir, n.rel("")); errno != 0 {
+		return nil, errno
+	}
+	return n.LoopbackNode.Readdir(ctx)
+}
+
+func (n *faultNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := n.fault(ctx, FSOpMkdir, n.rel(name)); errno != 0 {
+		return nil, errno
+	}
+	return n.LoopbackNode.Mkdir(ctx, name, mode, out)
+}
+
+func (n *faultNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if errno := n.fault(ctx, FSOpRmdir, n.rel(name)); errno != 0 {
+		return errno
+	}
+	return n.LoopbackNode.Rmdir(ctx, name)
+}
+
+func (n *faultNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	if errno := n.fault(ctx, FSOpUnlink, n.rel(name)); errno != 0 {
+		return errno
+	}
+	return n.LoopbackNode.Unlink(ctx, name)
+}
+
+func (n *faultNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if errno := n.fault(ctx, FSOpRename, n.rel(name)); errno != 0 {
+		return errno
+	}
+	// The loopback implementation expects its own node type as the new parent.
+	if p, ok := newParent.(*faultNode); ok {
+		newParent = p.LoopbackNode
+	}
+	return n.LoopbackNode.Rename(ctx, name, newParent, newName, flags)
+}
+
+func (n *faultNode) wrapFile(fh fs.FileHandle, rel string) fs.FileHandle {
+	lf, ok := fh.(*fs.LoopbackFile)
+	if !ok {
+		return fh
+	}
+	return &faultFile{LoopbackFile: lf, node: n, rel: rel}
+}
+
+// faultFile applies read/write/fsync faults to an open loopback file.
+type faultFile struct {
+	*fs.LoopbackFile
+	node *faultNode
+	rel  string
+}
+
+var (
+	_ fs.FileReader  = (*faultFile)(nil)
+	_ fs.FileWriter  = (*faultFile)(nil)
+	_ fs.FileFsyncer = (*faultFile)(nil)
+)
+
+func (f *faultFile) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	fault := f.node.inj.Decide(FSOpRead, f.rel)
+	if !fault.Wait(ctx) {
+		return nil, syscall.EINTR
+	}
+	if fault.Errno != 0 {
+		return nil, fault.Errno
+	}
+	if fault.ShortRead > 0 && fault.ShortRead < len(dest) {
+		dest = dest[:fault.ShortRead]
+	}
+	return f.LoopbackFile.Read(ctx, dest, off)
+}
+
+func (f *faultFile) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	if errno := f.node.fault(ctx, FSOpWrite, f.rel); errno != 0 {
+		return 0, errno
+	}
+	return f.LoopbackFile.Write(ctx, data, off)
+}
+
+func (f *faultFile) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	if errno := f.node.fault(ctx, FSOpFsync, f.rel); errno != 0 {
+		return errno
+	}
+	return f.LoopbackFile.Fsync(ctx, flags)
+}
+
+// PassthroughFd disables kernel passthrough so every read and write reaches the injector.
+func (f *faultFile) PassthroughFd() (int, bool) {
+	return -1, false
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package exec
+
+import "errors"
+
+// unmountInterposed is unsupported where no interposing backend exists.
+func unmountInterposed(path string) error {
+	return errors.ErrUnsupported
+}
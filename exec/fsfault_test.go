@@ -0,0 +1,199 @@
+package exec
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFSFaultRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FSFaultRule
+		op   FSOp
+		rel  string
+		want bool
+	}{
+		{"no ops or globs", FSFaultRule{}, FSOpUnlink, "a/b.txt", true},
+		{"op listed", FSFaultRule{Ops: []FSOp{FSOpRead, FSOpWrite}}, FSOpWrite, "x", true},
+		{"op not listed", FSFaultRule{Ops: []FSOp{FSOpRead, FSOpWrite}}, FSOpFsync, "x", false},
+		{"all ops", FSFaultRule{Ops: []FSOp{FSOpAll}}, FSOpRename, "x", true},
+		{"all among others", FSFaultRule{Ops: []FSOp{FSOpRead, FSOpAll}}, FSOpMkdir, "x", true},
+		{"base name at top", FSFaultRule{Globs: []string{"*.db"}}, FSOpRead, "app.db", true},
+		{"base name nested", FSFaultRule{Globs: []string{"*.db"}}, FSOpRead, "data/2024/app.db", true},
+		{"base name mismatch", FSFaultRule{Globs: []string{"*.db"}}, FSOpRead, "data/app.log", false},
+		{"path glob", FSFaultRule{Globs: []string{"wal/*"}}, FSOpWrite, "wal/000001.log", true},
+		{"path glob not recursive", FSFaultRule{Globs: []string{"wal/*"}}, FSOpWrite, "wal/old/000001.log", false},
+		{"path glob ignores base", FSFaultRule{Globs: []string{"wal/*"}}, FSOpWrite, "other/wal", false},
+		{"path glob other dir", FSFaultRule{Globs: []string{"wal/*"}}, FSOpWrite, "data/wal/1.log", false},
+		{"second glob", FSFaultRule{Globs: []string{"*.db", "wal/*"}}, FSOpWrite, "wal/1.log", true},
+		{"op and glob", FSFaultRule{Ops: []FSOp{FSOpRead}, Globs: []string{"*.db"}}, FSOpWrite, "app.db", false},
+		{"root", FSFaultRule{Globs: []string{"*"}}, FSOpReaddir, ".", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.op, tt.rel); got != tt.want {
+				t.Errorf("matches(%s, %q) = %v, want %v", tt.op, tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFSOps(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []FSOp
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"read", []FSOp{FSOpRead}, false},
+		{" Read , WRITE,,fsync ", []FSOp{FSOpRead, FSOpWrite, FSOpFsync}, false},
+		{"all", []FSOp{FSOpAll}, false},
+		{"*", []FSOp{FSOpAll}, false},
+		{"read,chmod", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFSOps(tt.in)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("ParseFSOps(%q) = %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseFSGlobs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"*.db, wal/*,", []string{"*.db", "wal/*"}, false},
+		{"[abc", nil, true},
+		{"*.db,logs/[", nil, true},
+		{`a\`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFSGlobs(tt.in)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("ParseFSGlobs(%q) = %q, %v; want %q (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseFSErrno(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    syscall.Errno
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"ENOSPC", syscall.ENOSPC, false},
+		{"eio", syscall.EIO, false},
+		{"EROFS", syscall.EROFS, false},
+		{"ENOMEM", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFSErrno(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFSErrno(%q) = %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFSFaultInjectorDecide(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []FSFaultRule
+		op    FSOp
+		rel   string
+		want  FSFault
+	}{
+		{"no rules", nil, FSOpRead, "a", FSFault{}},
+		{"error", []FSFaultRule{{Errno: syscall.ENOSPC, Percent: 100}}, FSOpWrite, "a", FSFault{Errno: syscall.ENOSPC}},
+		{"latency", []FSFaultRule{{Latency: time.Second, Percent: 100}}, FSOpRead, "a", FSFault{Delay: time.Second}},
+		{"latencies add", []FSFaultRule{
+			{Latency: time.Second, Percent: 100},
+			{Latency: 2 * time.Second, Percent: 100},
+		}, FSOpRead, "a", FSFault{Delay: 3 * time.Second}},
+		{"first error wins", []FSFaultRule{
+			{Errno: syscall.EIO, Percent: 100},
+			{Errno: syscall.EROFS, Percent: 100},
+		}, FSOpWrite, "a", FSFault{Errno: syscall.EIO}},
+		{"short read", []FSFaultRule{{ShortRead: 10, Percent: 100}}, FSOpRead, "a", FSFault{ShortRead: 10}},
+		{"shortest read wins", []FSFaultRule{
+			{ShortRead: 10, Percent: 100},
+			{ShortRead: 4, Percent: 100},
+			{ShortRead: 7, Percent: 100},
+		}, FSOpRead, "a", FSFault{ShortRead: 4}},
+		{"short read only on read", []FSFaultRule{{ShortRead: 10, Percent: 100}}, FSOpWrite, "a", FSFault{}},
+		{"zero percent", []FSFaultRule{{Errno: syscall.EIO, Percent: 0}}, FSOpRead, "a", FSFault{}},
+		{"unmatched rule", []FSFaultRule{
+			{Ops: []FSOp{FSOpWrite}, Errno: syscall.EIO, Percent: 100},
+			{Globs: []string{"*.db"}, Latency: time.Second, Percent: 100},
+		}, FSOpRead, "a.db", FSFault{Delay: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inj := NewFSFaultInjector(tt.rules...)
+			got := inj.Decide(tt.op, tt.rel)
+			if got != tt.want {
+				t.Errorf("Decide = %+v, want %+v", got, tt.want)
+			}
+			var wantInjected int64
+			if tt.want != (FSFault{}) {
+				wantInjected = 1
+			}
+			if inj.Injected() != wantInjected {
+				t.Errorf("Injected = %d, want %d", inj.Injected(), wantInjected)
+			}
+		})
+	}
+}
+
+// TestFSFaultInjectorPercent checks the trigger rate and that a seeded source makes the
+// same decisions.
+func TestFSFaultInjectorPercent(t *testing.T) {
+	const n, percent = 20000, 30.0
+	var runs [2][]bool
+	for i := range runs {
+		inj := NewFSFaultInjector(FSFaultRule{Errno: syscall.EIO, Percent: percent})
+		inj.rng = rand.New(rand.NewPCG(1, 2))
+		for j := 0; j < n; j++ {
+			runs[i] = append(runs[i], inj.Decide(FSOpRead, "a").Errno != 0)
+		}
+		if rate := float64(inj.Injected()) / n * 100; math.Abs(rate-percent) > 1.5 {
+			t.Errorf("triggered %.2f%% of operations, want %.0f%%", rate, percent)
+		}
+	}
+	if !slices.Equal(runs[0], runs[1]) {
+		t.Error("the same seed made different decisions")
+	}
+}
+
+func TestFSFaultWait(t *testing.T) {
+	if !(FSFault{}).Wait(context.Background()) {
+		t.Error("Wait without a delay reported cancellation")
+	}
+
+	start := time.Now()
+	if !(FSFault{Delay: 20 * time.Millisecond}).Wait(context.Background()) {
+		t.Error("Wait reported cancellation")
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Wait returned after %v, want at least 20ms", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if (FSFault{Delay: time.Minute}).Wait(ctx) {
+		t.Error("Wait outlived its context")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("canceled Wait took %v", d)
+	}
+}
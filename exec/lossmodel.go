@@ -0,0 +1,134 @@
+package exec
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// LossModelKind names a packet loss model.
+type LossModelKind string
+
+const (
+	// LossBernoulli drops each packet independently with a fixed probability.
+	LossBernoulli LossModelKind = "bernoulli"
+	// LossGilbertElliott alternates between a good and a bad state with their own loss
+	// rates, producing loss bursts whose length follows the bad-state dwell time.
+	LossGilbertElliott LossModelKind = "gilbert-elliott"
+	// LossCorrelated keeps the configured average loss rate but makes each decision
+	// depend on the previous one, so drops cluster as the correlation rises.
+	LossCorrelated LossModelKind = "correlated"
+)
+
+// LossModel decides, packet by packet, whether to drop. Implementations keep state
+// between calls and are not safe for concurrent use.
+type LossModel interface {
+	Drop(rng *rand.Rand) bool
+	// Rate is the long-run fraction of packets dropped.
+	Rate() float64
+}
+
+// LossParams configures NewLossModel. Percentages are 0-100.
+type LossParams struct {
+	Percent     float64 // bernoulli and correlated: average loss
+	Correlation float64 // correlated: correlation between successive decisions
+
+	// Gilbert-Elliott: P is the chance per packet of moving from the good state to the
+	// bad one and R of moving back; BadLoss and GoodLoss are the loss rates inside each
+	// state (netem's 1-h and 1-k).
+	P, R              float64
+	BadLoss, GoodLoss float64
+}
+
+// ParseLossModelKind validates a loss model name.
+func ParseLossModelKind(s string) (LossModelKind, error) {
+	switch k := LossModelKind(s); k {
+	case LossBernoulli, LossGilbertElliott, LossCorrelated:
+		return k, nil
+	case "":
+		return LossBernoulli, nil
+	default:
+		return "", fmt.Errorf("unknown loss model %q (expected bernoulli, gilbert-elliott or correlated)", s)
+	}
+}
+
+// NewLossModel builds a loss model of kind from params.
+func NewLossModel(kind LossModelKind, params LossParams) (LossModel, error) {
+	for name, v := range map[string]float64{
+		"loss": params.Percent, "loss-correlation": params.Correlation,
+		"ge-p": params.P, "ge-r": params.R, "ge-bad-loss": params.BadLoss, "ge-good-loss": params.GoodLoss,
+	} {
+		if v < 0 || v > 100 {
+			return nil, fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	switch kind {
+	case LossBernoulli, "":
+		return &bernoulliLoss{p: params.Percent / 100}, nil
+	case LossCorrelated:
+		return &correlatedLoss{p: params.Percent / 100, rho: params.Correlation / 100}, nil
+	case LossGilbertElliott:
+		if params.P <= 0 || params.R <= 0 {
+			return nil, fmt.Errorf("gilbert-elliott loss needs --ge-p and --ge-r above 0")
+		}
+		return &gilbertElliottLoss{
+			p: params.P / 100, r: params.R / 100,
+			badLoss: params.BadLoss / 100, goodLoss: params.GoodLoss / 100,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown loss model %q", kind)
+	}
+}
+
+type bernoulliLoss struct{ p float64 }
+
+func (m *bernoulliLoss) Drop(rng *rand.Rand) bool { return m.p > 0 && rng.Float64() < m.p }
+func (m *bernoulliLoss) Rate() float64            { return m.p }
+
+// correlatedLoss is a two-state Markov chain whose stationary drop rate is p and whose
+// successive decisions have correlation rho: after a drop the next packet is dropped
+// with p+rho(1-p), after a delivery with p(1-rho). Loss bursts are geometric with mean
+// 1/((1-p)(1-rho)). Unlike netem's correlated random number, this keeps the average
+// loss at p for every rho.
+type correlatedLoss struct {
+	p, rho  float64
+	dropped bool
+}
+
+func (m *correlatedLoss) Drop(rng *rand.Rand) bool {
+	prob := m.p * (1 - m.rho)
+	if m.dropped {
+		prob = m.p + m.rho*(1-m.p)
+	}
+	m.dropped = prob > 0 && rng.Float64() < prob
+	return m.dropped
+}
+
+func (m *correlatedLoss) Rate() float64 { return m.p }
+
+// gilbertElliottLoss is the Gilbert-Elliott channel: the state changes before each
+// packet, then the packet is dropped with the current state's loss rate. With
+// BadLoss 100 and GoodLoss 0 it is the simple Gilbert model, whose loss bursts are
+// geometric with mean 1/r.
+type gilbertElliottLoss struct {
+	p, r              float64
+	badLoss, goodLoss float64
+	bad               bool
+}
+
+func (m *gilbertElliottLoss) Drop(rng *rand.Rand) bool {
+	if m.bad {
+		m.bad = rng.Float64() >= m.r
+	} else {
+		m.bad = rng.Float64() < m.p
+	}
+	loss := m.goodLoss
+	if m.bad {
+		loss = m.badLoss
+	}
+	return loss > 0 && rng.Float64() < loss
+}
+
+func (m *gilbertElliottLoss) Rate() float64 {
+	bad := m.p / (m.p + m.r)
+	return bad*m.badLoss + (1-bad)*m.goodLoss
+}
@@ -0,0 +1,102 @@
+package exec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// lossRun drives m for n packets and returns the observed drop rate and mean loss
+// burst length.
+func lossRun(m LossModel, rng *rand.Rand, n int) (rate, meanBurst float64) {
+	var drops, bursts, run int
+	for i := 0; i < n; i++ {
+		if m.Drop(rng) {
+			drops++
+			run++
+			continue
+		}
+		if run > 0 {
+			bursts++
+			run = 0
+		}
+	}
+	if run > 0 {
+		bursts++
+	}
+	if bursts == 0 {
+		return float64(drops) / float64(n), 0
+	}
+	return float64(drops) / float64(n), float64(drops) / float64(bursts)
+}
+
+func TestLossModelStatistics(t *testing.T) {
+	const packets = 1_000_000
+	tests := []struct {
+		name      string
+		kind      LossModelKind
+		params    LossParams
+		wantBurst float64 // 0 skips the burst check
+	}{
+		{"bernoulli", LossBernoulli, LossParams{Percent: 5}, 1 / 0.95},
+		{"correlated 25%", LossCorrelated, LossParams{Percent: 5, Correlation: 25}, 1 / (0.95 * 0.75)},
+		{"correlated 75%", LossCorrelated, LossParams{Percent: 10, Correlation: 75}, 1 / (0.90 * 0.25)},
+		{"gilbert", LossGilbertElliott, LossParams{P: 1, R: 25, BadLoss: 100}, 1 / 0.25},
+		{"gilbert long bursts", LossGilbertElliott, LossParams{P: 0.5, R: 10, BadLoss: 100}, 1 / 0.10},
+		{"gilbert-elliott", LossGilbertElliott, LossParams{P: 2, R: 20, BadLoss: 60, GoodLoss: 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewLossModel(tt.kind, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rate, burst := lossRun(m, rand.New(rand.NewSource(1)), packets)
+
+			if want := m.Rate(); math.Abs(rate-want) > 0.05*want {
+				t.Errorf("drop rate = %.4f, Rate() = %.4f", rate, want)
+			}
+			if tt.wantBurst > 0 && math.Abs(burst-tt.wantBurst) > 0.05*tt.wantBurst {
+				t.Errorf("mean burst = %.3f, want %.3f", burst, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func TestLossModelSeedReproducible(t *testing.T) {
+	params := LossParams{P: 1, R: 25, BadLoss: 100}
+	var runs [2][]bool
+	for i := range runs {
+		m, err := NewLossModel(LossGilbertElliott, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rng := rand.New(rand.NewSource(42))
+		for j := 0; j < 1000; j++ {
+			runs[i] = append(runs[i], m.Drop(rng))
+		}
+	}
+	for j := range runs[0] {
+		if runs[0][j] != runs[1][j] {
+			t.Fatalf("decision %d differs between runs with the same seed", j)
+		}
+	}
+}
+
+func TestNewLossModelValidation(t *testing.T) {
+	tests := []struct {
+		kind   LossModelKind
+		params LossParams
+	}{
+		{LossBernoulli, LossParams{Percent: 101}},
+		{LossCorrelated, LossParams{Percent: 5, Correlation: -1}},
+		{LossGilbertElliott, LossParams{P: 0, R: 10, BadLoss: 100}},
+		{LossGilbertElliott, LossParams{P: 1, R: 0, BadLoss: 100}},
+		{"burst", LossParams{Percent: 5}},
+	}
+	for _, tt := range tests {
+		if _, err := NewLossModel(tt.kind, tt.params); err == nil {
+			t.Errorf("NewLossModel(%q, %+v) succeeded, want error", tt.kind, tt.params)
+		}
+	}
+}
@@ -2,12 +2,62 @@ package exec
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
 	"time"
+
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// MemoryMode selects how a MemoryRunner reaches its allocation target.
+type MemoryMode string
+
+const (
+	// MemoryModeHold allocates the whole target at once and holds it.
+	MemoryModeHold MemoryMode = "hold"
+	// MemoryModeLeak grows the allocation at a fixed rate until a maximum is reached.
+	MemoryModeLeak MemoryMode = "leak"
+	// MemoryModeRamp grows the allocation linearly to reach the target after a duration.
+	MemoryModeRamp MemoryMode = "ramp"
+	// MemoryModeTarget grows or shrinks the allocation to hold system-wide usage at a percent.
+	MemoryModeTarget MemoryMode = "target"
+	// MemoryModeCache fills the OS page cache by writing and re-reading a temporary file.
+	MemoryModeCache MemoryMode = "cache"
+	// MemoryModeMapped holds a temporary file mapped into memory with every page dirtied.
+	MemoryModeMapped MemoryMode = "mapped"
 )
 
+const (
+	memoryPageSize  = 4096
+	memoryChunkSize = 16 << 20 // allocate in 16 MB chunks so growth is incremental
+	memoryGrowTick  = 100 * time.Millisecond
+	// memoryTargetTick is how often target mode re-reads system memory usage.
+	memoryTargetTick = 500 * time.Millisecond
+	// memoryGuardTick is how often the watchdog checks free memory.
+	memoryGuardTick = 500 * time.Millisecond
+)
+
+// ErrMemoryCritical indicates the watchdog released the allocation because free memory
+// dropped below the critical threshold.
+var ErrMemoryCritical = errors.New("free memory below critical threshold; allocation released")
+
 // MemoryRunner allocates and holds memory until the context is canceled.
 type MemoryRunner struct {
+	mode      MemoryMode
 	sizeBytes int64
+	rate      int64
+	rampTime  time.Duration
+	targetPct float64
+	resident  bool
+	allocator memoryAllocator
+
+	minFree      int64
+	criticalFree int64
+
+	allocated atomic.Int64
+	artifactTracker
 }
 
 // NewMemoryRunner builds a MemoryRunner for the requested size in bytes.
@@ -16,28 +66,223 @@ func NewMemoryRunner(sizeBytes int64) *MemoryRunner {
 	if sizeBytes < minBytes {
 		sizeBytes = minBytes
 	}
-	return &MemoryRunner{sizeBytes: sizeBytes}
+	return &MemoryRunner{mode: MemoryModeHold, sizeBytes: sizeBytes, allocator: heapAllocator{}}
+}
+
+// NewMemoryLeakRunner builds a MemoryRunner that grows by rateBytesPerSec until maxBytes.
+func NewMemoryLeakRunner(rateBytesPerSec, maxBytes int64) *MemoryRunner {
+	r := NewMemoryRunner(maxBytes)
+	r.mode = MemoryModeLeak
+	r.rate = max(rateBytesPerSec, 1)
+	return r
+}
+
+// NewMemoryRampRunner builds a MemoryRunner that reaches sizeBytes linearly over rampTime.
+func NewMemoryRampRunner(sizeBytes int64, rampTime time.Duration) *MemoryRunner {
+	r := NewMemoryRunner(sizeBytes)
+	if rampTime > 0 {
+		r.mode = MemoryModeRamp
+		r.rampTime = rampTime
+	}
+	return r
+}
+
+// NewMemoryTargetRunner builds a MemoryRunner that keeps host memory usage at usedPercent
+// of total, shrinking its own allocation when other processes need the memory.
+func NewMemoryTargetRunner(usedPercent float64) *MemoryRunner {
+	return &MemoryRunner{mode: MemoryModeTarget, targetPct: min(max(usedPercent, 1), 100), allocator: heapAllocator{}}
+}
+
+// UseResidentMemory allocates outside the Go heap (mmap/VirtualAlloc) and re-touches
+// every page periodically so the memory stays in RAM. With lock, pages are also pinned
+// (mlock/VirtualLock), which may require raised limits or privileges.
+func (r *MemoryRunner) UseResidentMemory(lock bool) {
+	r.resident = true
+	r.allocator = residentAllocator{lock: lock}
+}
+
+// NewMemoryFileRunner builds a MemoryRunner that applies sizeBytes of file-backed
+// pressure using mode MemoryModeCache or MemoryModeMapped.
+func NewMemoryFileRunner(mode MemoryMode, sizeBytes int64) *MemoryRunner {
+	r := NewMemoryRunner(sizeBytes)
+	r.mode = mode
+	return r
+}
+
+// SetGuard keeps at least minFree bytes of host memory available while growing and
+// releases everything if availability falls below criticalFree. Zero disables a check.
+func (r *MemoryRunner) SetGuard(minFree, criticalFree int64) {
+	r.minFree = max(minFree, 0)
+	r.criticalFree = max(criticalFree, 0)
+}
+
+// Mode reports how the runner grows its allocation.
+func (r *MemoryRunner) Mode() MemoryMode {
+	return r.mode
+}
+
+// AllocatedBytes reports how many bytes the runner currently holds.
+func (r *MemoryRunner) AllocatedBytes() int64 {
+	return r.allocated.Load()
+}
+
+// TargetBytes reports the final allocation size the runner grows towards.
+func (r *MemoryRunner) TargetBytes() int64 {
+	return r.sizeBytes
 }
 
 // Run allocates, touches pages, and keeps the memory until cancellation.
 func (r *MemoryRunner) Run(ctx context.Context) error {
-	buf := make([]byte, r.sizeBytes)
+	if r.mode == MemoryModeCache || r.mode == MemoryModeMapped {
+		return r.runFileBacked(ctx)
+	}
 
-	for i := int64(0); i < r.sizeBytes; i += 4096 {
-		buf[i] = byte(i)
+	var chunks [][]byte
+	defer func() {
+		for _, c := range chunks {
+			_ = r.allocator.free(c)
+		}
+		r.allocated.Store(0)
+	}()
+
+	grow := func(target int64) error {
+		if r.minFree > 0 {
+			stats, err := mem.VirtualMemory()
+			if err != nil {
+				return err
+			}
+			// Never grow into the free-memory floor, whatever the mode asks for.
+			cur := r.allocated.Load()
+			headroom := int64(stats.Available) - r.minFree
+			target = min(target, cur+max(headroom, 0))
+		}
+		for cur := r.allocated.Load(); cur < target; cur = r.allocated.Load() {
+			n := min(target-cur, memoryChunkSize)
+			buf, err := r.allocator.alloc(n)
+			if err != nil {
+				return err
+			}
+			touchPages(buf)
+			chunks = append(chunks, buf)
+			r.allocated.Add(n)
+		}
+		return nil
 	}
 
+	shrink := func(target int64) error {
+		released := false
+		for len(chunks) > 0 && r.allocated.Load() > target {
+			last := chunks[len(chunks)-1]
+			chunks[len(chunks)-1] = nil
+			chunks = chunks[:len(chunks)-1]
+			r.allocated.Add(-int64(len(last)))
+			if err := r.allocator.free(last); err != nil {
+				return err
+			}
+			released = true
+		}
+		if released && !r.resident {
+			debug.FreeOSMemory()
+		}
+		return nil
+	}
+
+	if r.mode == MemoryModeHold {
+		if err := grow(r.sizeBytes); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	tick := memoryGrowTick
+	if r.mode == MemoryModeTarget {
+		tick = memoryTargetTick
+	}
+	growTicker := time.NewTicker(tick)
+	defer growTicker.Stop()
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
+	var guardC <-chan time.Time
+	if r.criticalFree > 0 {
+		guardTicker := time.NewTicker(memoryGuardTick)
+		defer guardTicker.Stop()
+		guardC = guardTicker.C
+	}
+
 	for {
+		var err error
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-growTicker.C:
+			elapsed := time.Since(start)
+			switch r.mode {
+			case MemoryModeLeak:
+				err = grow(min(int64(elapsed.Seconds()*float64(r.rate)), r.sizeBytes))
+			case MemoryModeRamp:
+				err = grow(min(int64(float64(r.sizeBytes)*elapsed.Seconds()/r.rampTime.Seconds()), r.sizeBytes))
+			case MemoryModeTarget:
+				stats, statErr := mem.VirtualMemory()
+				if statErr != nil {
+					return statErr
+				}
+				desired := r.adjustForTarget(stats.Total, stats.Used)
+				if desired > r.allocated.Load() {
+					err = grow(desired)
+				} else {
+					err = shrink(desired)
+				}
+			}
+		case <-guardC:
+			err = r.checkCritical()
 		case <-ticker.C:
-			if len(buf) > 0 {
-				buf[0] ^= 1
+			for _, c := range chunks {
+				if r.resident {
+					// Re-touch every page so the OS keeps the whole range in the working set.
+					touchPages(c)
+				} else {
+					c[0] ^= 1
+				}
 			}
 		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkCritical returns ErrMemoryCritical when host availability is below the
+// critical threshold.
+func (r *MemoryRunner) checkCritical() error {
+	stats, err := mem.VirtualMemory()
+	if err != nil {
+		return err
+	}
+	if int64(stats.Available) < r.criticalFree {
+		return fmt.Errorf("%w (available %d bytes, critical %d bytes)", ErrMemoryCritical, stats.Available, r.criticalFree)
+	}
+	return nil
+}
+
+// touchPages writes one byte per page so every page is backed by physical memory.
+func touchPages(buf []byte) {
+	for i := 0; i < len(buf); i += memoryPageSize {
+		buf[i]++
+	}
+}
+
+// adjustForTarget returns the allocation size that moves host usage towards the target
+// percent. Small deviations are ignored so the runner does not oscillate around the
+// target one chunk at a time.
+func (r *MemoryRunner) adjustForTarget(total, used uint64) int64 {
+	cur := r.allocated.Load()
+	desiredUsed := int64(float64(total) * r.targetPct / 100)
+	delta := desiredUsed - int64(used)
+
+	tolerance := max(int64(total)/200, memoryChunkSize) // 0.5% of RAM or one chunk
+	if delta > -tolerance && delta < tolerance {
+		return cur
 	}
+	return max(cur+delta, 0)
 }
@@ -0,0 +1,42 @@
+package exec
+
+// memoryAllocator hands out the buffers a MemoryRunner holds.
+type memoryAllocator interface {
+	alloc(n int64) ([]byte, error)
+	free(buf []byte) error
+}
+
+// heapAllocator allocates from the Go heap; memory is returned by the garbage collector.
+type heapAllocator struct{}
+
+func (heapAllocator) alloc(n int64) ([]byte, error) {
+	return make([]byte, n), nil
+}
+
+func (heapAllocator) free([]byte) error {
+	return nil
+}
+
+// residentAllocator maps memory directly from the OS so it is invisible to the Go
+// garbage collector, optionally pinning it in physical RAM.
+type residentAllocator struct {
+	lock bool
+}
+
+func (a residentAllocator) alloc(n int64) ([]byte, error) {
+	buf, err := osAllocMemory(n)
+	if err != nil {
+		return nil, err
+	}
+	if a.lock {
+		if err := osLockMemory(buf); err != nil {
+			_ = osFreeMemory(buf, false)
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func (a residentAllocator) free(buf []byte) error {
+	return osFreeMemory(buf, a.lock)
+}
@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// memoryCacheRefresh is how often cache mode re-reads its file to keep it hot.
+const memoryCacheRefresh = 10 * time.Second
+
+// runFileBacked applies file-backed memory pressure using a temporary file created the
+// same way as disk fill. The backing file is removed when the context ends.
+func (r *MemoryRunner) runFileBacked(ctx context.Context) error {
+	f, err := createFillFile("")
+	if err != nil {
+		return err
+	}
+	backingPath := f.Name()
+	defer func() {
+		f.Close()
+		os.Remove(backingPath)
+		r.allocated.Store(0)
+	}()
+	if err := r.recordArtifact(backingPath); err != nil {
+		return err
+	}
+
+	var mapped []byte
+	switch r.mode {
+	case MemoryModeCache:
+		// Written pages land in the page cache; reading them back keeps them resident.
+		if _, err := writeFill(ctx, f, r.sizeBytes, fillOptions{progress: &r.allocated}); err != nil {
+			return err
+		}
+		if err := readAll(ctx, f); err != nil {
+			return err
+		}
+	case MemoryModeMapped:
+		if err := f.Truncate(r.sizeBytes); err != nil {
+			return err
+		}
+		var unmap func() error
+		mapped, unmap, err = osMapFile(f, r.sizeBytes)
+		if err != nil {
+			return err
+		}
+		defer unmap()
+		for i := 0; i < len(mapped); i += memoryPageSize {
+			if i%memoryChunkSize == 0 && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			mapped[i]++
+			r.allocated.Store(int64(i + 1))
+		}
+		r.allocated.Store(int64(len(mapped)))
+	}
+
+	refresh := time.NewTicker(memoryCacheRefresh)
+	defer refresh.Stop()
+
+	var guardC <-chan time.Time
+	if r.criticalFree > 0 {
+		guardTicker := time.NewTicker(memoryGuardTick)
+		defer guardTicker.Stop()
+		guardC = guardTicker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-guardC:
+			err = r.checkCritical()
+		case <-refresh.C:
+			if mapped != nil {
+				touchPages(mapped)
+			} else {
+				err = readAll(ctx, f)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readAll reads f from the start so its pages are pulled (back) into the page cache.
+func readAll(ctx context.Context, f *os.File) error {
+	buf := make([]byte, 1024*1024)
+	var off int64
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n, err := f.ReadAt(buf, off)
+		off += int64(n)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux && !darwin && !windows
+
+package exec
+
+import (
+	"errors"
+	"os"
+)
+
+// osAllocMemory is unavailable on this platform.
+func osAllocMemory(n int64) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// osLockMemory is unavailable on this platform.
+func osLockMemory(buf []byte) error {
+	return errors.ErrUnsupported
+}
+
+// osFreeMemory has nothing to release, as osAllocMemory never succeeds here.
+func osFreeMemory(buf []byte, locked bool) error {
+	return nil
+}
+
+// osMapFile is unavailable on this platform.
+func osMapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
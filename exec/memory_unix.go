@@ -0,0 +1,46 @@
+//go:build linux || darwin
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// osAllocMemory maps n bytes of anonymous private memory.
+func osAllocMemory(n int64) ([]byte, error) {
+	buf, err := syscall.Mmap(-1, 0, int(n), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %d bytes: %w", n, err)
+	}
+	return buf, nil
+}
+
+// osLockMemory pins buf in physical memory with mlock.
+func osLockMemory(buf []byte) error {
+	if err := syscall.Mlock(buf); err != nil {
+		return fmt.Errorf("mlock %d bytes (check RLIMIT_MEMLOCK): %w", len(buf), err)
+	}
+	return nil
+}
+
+// osFreeMemory releases memory obtained from osAllocMemory.
+func osFreeMemory(buf []byte, locked bool) error {
+	if locked {
+		_ = syscall.Munlock(buf)
+	}
+	if err := syscall.Munmap(buf); err != nil {
+		return fmt.Errorf("munmap: %w", err)
+	}
+	return nil
+}
+
+// osMapFile maps the first size bytes of f shared read-write and returns an unmap function.
+func osMapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	buf, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mmap file: %w", err)
+	}
+	return buf, func() error { return syscall.Munmap(buf) }, nil
+}
@@ -0,0 +1,103 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	memCommit     = 0x1000
+	memReserve    = 0x2000
+	memRelease    = 0x8000
+	pageReadWrite = 0x04
+)
+
+var (
+	kernel32DLL                  = syscall.NewLazyDLL("kernel32.dll")
+	procVirtualAlloc             = kernel32DLL.NewProc("VirtualAlloc")
+	procVirtualFree              = kernel32DLL.NewProc("VirtualFree")
+	procVirtualLock              = kernel32DLL.NewProc("VirtualLock")
+	procVirtualUnlock            = kernel32DLL.NewProc("VirtualUnlock")
+	procGetProcessWorkingSetSize = kernel32DLL.NewProc("GetProcessWorkingSetSize")
+	procSetProcessWorkingSetSize = kernel32DLL.NewProc("SetProcessWorkingSetSize")
+)
+
+// osAllocMemory commits n bytes of private memory with VirtualAlloc.
+func osAllocMemory(n int64) ([]byte, error) {
+	addr, _, err := procVirtualAlloc.Call(0, uintptr(n), memCommit|memReserve, pageReadWrite)
+	if addr == 0 {
+		return nil, fmt.Errorf("VirtualAlloc %d bytes: %w", n, err)
+	}
+	// The region lives outside the Go heap, so converting the raw address is safe.
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	return unsafe.Slice((*byte)(ptr), n), nil
+}
+
+// osLockMemory pins buf in physical memory with VirtualLock. The process working set
+// is grown first because VirtualLock cannot lock more than the minimum working set.
+func osLockMemory(buf []byte) error {
+	if err := growWorkingSet(int64(len(buf))); err != nil {
+		return err
+	}
+	r1, _, err := procVirtualLock.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r1 == 0 {
+		return fmt.Errorf("VirtualLock %d bytes: %w", len(buf), err)
+	}
+	return nil
+}
+
+// osFreeMemory releases memory obtained from osAllocMemory.
+func osFreeMemory(buf []byte, locked bool) error {
+	addr := uintptr(unsafe.Pointer(&buf[0]))
+	if locked {
+		procVirtualUnlock.Call(addr, uintptr(len(buf)))
+		_ = growWorkingSet(-int64(len(buf)))
+	}
+	r1, _, err := procVirtualFree.Call(addr, 0, memRelease)
+	if r1 == 0 {
+		return fmt.Errorf("VirtualFree: %w", err)
+	}
+	return nil
+}
+
+func growWorkingSet(delta int64) error {
+	proc, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	var minSize, maxSize uintptr
+	r1, _, err := procGetProcessWorkingSetSize.Call(uintptr(proc), uintptr(unsafe.Pointer(&minSize)), uintptr(unsafe.Pointer(&maxSize)))
+	if r1 == 0 {
+		return fmt.Errorf("GetProcessWorkingSetSize: %w", err)
+	}
+	minSize = uintptr(int64(minSize) + delta)
+	maxSize = uintptr(int64(maxSize) + delta)
+	r1, _, err = procSetProcessWorkingSetSize.Call(uintptr(proc), minSize, maxSize)
+	if r1 == 0 {
+		return fmt.Errorf("SetProcessWorkingSetSize: %w", err)
+	}
+	return nil
+}
+
+// osMapFile maps the first size bytes of f read-write and returns an unmap function.
+func osMapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READWRITE, uint32(size>>32), uint32(size), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CreateFileMapping: %w", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, nil, fmt.Errorf("MapViewOfFile: %w", err)
+	}
+	// The view lives outside the Go heap, so converting the raw address is safe.
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	unmap := func() error {
+		err := syscall.UnmapViewOfFile(addr)
+		syscall.CloseHandle(h)
+		return err
+	}
+	return unsafe.Slice((*byte)(ptr), size), unmap, nil
+}
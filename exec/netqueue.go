@@ -0,0 +1,151 @@
+package exec
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// queuedPacket is a packet waiting in a delayQueue for its release time.
+type queuedPacket struct {
+	data    []byte
+	addr    PacketAddress
+	release time.Time
+	seq     uint64 // arrival order, keeps packets with equal release times in order
+
+	// holdFor is how many later packets in the same direction are sent before this one
+	// when it is selected for reordering; see NetworkDelayRunner.sendLoop.
+	holdFor int
+	holding bool
+	sent    bool
+}
+
+// packetHeap orders packets by release time, then arrival.
+type packetHeap []*queuedPacket
+
+func (h packetHeap) Len() int { return len(h) }
+func (h packetHeap) Less(i, j int) bool {
+	if h[i].release.Equal(h[j].release) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].release.Before(h[j].release)
+}
+func (h packetHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *packetHeap) Push(x any)   { *h = append(*h, x.(*queuedPacket)) }
+func (h *packetHeap) Pop() any {
+	old := *h
+	p := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return p
+}
+
+// delayQueue is a bounded, time-ordered packet queue between the receive goroutine,
+// which pushes packets with their release times, and the send goroutine, which pops
+// each one when it is due. Packets are therefore delayed independently of each other.
+type delayQueue struct {
+	mu       sync.Mutex
+	heap     packetHeap
+	limit    int
+	seq      uint64
+	closed   bool
+	flushing bool          // every queued packet is due now
+	wake     chan struct{} // signaled when the earliest release may have changed
+}
+
+func newDelayQueue(limit int) *delayQueue {
+	return &delayQueue{limit: max(limit, 1), wake: make(chan struct{}, 1)}
+}
+
+// push adds p, reporting false when the queue is full and p was not queued.
+func (q *delayQueue) push(p *queuedPacket) bool {
+	q.mu.Lock()
+	if len(q.heap) >= q.limit {
+		q.mu.Unlock()
+		return false
+	}
+	q.seq++
+	p.seq = q.seq
+	heap.Push(&q.heap, p)
+	q.mu.Unlock()
+	q.signal()
+	return true
+}
+
+// requeue puts p back with a new release time regardless of the limit; p was already
+// counted against it when first pushed.
+func (q *delayQueue) requeue(p *queuedPacket) {
+	q.mu.Lock()
+	q.seq++
+	p.seq = q.seq
+	heap.Push(&q.heap, p)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// close stops further pushes; next returns nil once the remaining packets are popped.
+func (q *delayQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// flush makes every packet due immediately, so the experiment can end without
+// dropping what is still queued.
+func (q *delayQueue) flush() {
+	q.mu.Lock()
+	q.flushing = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *delayQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// len reports how many packets are waiting.
+func (q *delayQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.heap)
+}
+
+// next blocks until the earliest packet is due, or the queue is flushing, and pops it.
+// It returns nil only once the queue is closed and empty.
+func (q *delayQueue) next() *queuedPacket {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		if len(q.heap) == 0 {
+			closed := q.closed
+			q.mu.Unlock()
+			if closed {
+				return nil
+			}
+			<-q.wake
+			continue
+		}
+		head := q.heap[0]
+		wait := time.Until(head.release)
+		if wait <= 0 || q.flushing {
+			heap.Pop(&q.heap)
+			q.mu.Unlock()
+			return head
+		}
+		q.mu.Unlock()
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-q.wake:
+			timer.Stop()
+		}
+	}
+}
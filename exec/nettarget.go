@@ -0,0 +1,261 @@
+package exec
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// NetTarget selects traffic with ChaosBlade-style flags instead of a hand-written
+// WinDivert filter. Port lists accept single ports and ranges ("80,8000-8080"); IP
+// lists accept addresses and CIDR prefixes. Remote and local are relative to this host.
+type NetTarget struct {
+	RemotePorts   string
+	LocalPorts    string
+	DestinationIP string
+	ExcludePorts  string
+	ExcludeIPs    string
+	Protocol      string // tcp, udp, icmp or all (empty)
+	Direction     string // outbound (default), inbound or both
+}
+
+// IsZero reports whether no selector is set.
+func (t NetTarget) IsZero() bool {
+	return t == NetTarget{}
+}
+
+type portRange struct{ lo, hi uint16 }
+
+// Filter compiles the selectors into a WinDivert filter expression, ANDed with base
+// when base is non-empty.
+func (t NetTarget) Filter(base string) (string, error) {
+	protos, err := targetProtocols(t.Protocol)
+	if err != nil {
+		return "", err
+	}
+	remote, err := parsePortList(t.RemotePorts)
+	if err != nil {
+		return "", fmt.Errorf("remote-port: %w", err)
+	}
+	local, err := parsePortList(t.LocalPorts)
+	if err != nil {
+		return "", fmt.Errorf("local-port: %w", err)
+	}
+	excludePorts, err := parsePortList(t.ExcludePorts)
+	if err != nil {
+		return "", fmt.Errorf("exclude-port: %w", err)
+	}
+	dest, err := parsePrefixList(t.DestinationIP)
+	if err != nil {
+		return "", fmt.Errorf("destination-ip: %w", err)
+	}
+	excludeIPs, err := parsePrefixList(t.ExcludeIPs)
+	if err != nil {
+		return "", fmt.Errorf("exclude-ip: %w", err)
+	}
+	if len(remote)+len(local)+len(excludePorts) > 0 && !hasPortProtocol(protos) {
+		return "", fmt.Errorf("ports need protocol tcp or udp")
+	}
+
+	var dirs []bool // outbound?
+	switch t.Direction {
+	case "", "outbound":
+		dirs = []bool{true}
+	case "inbound":
+		dirs = []bool{false}
+	case "both":
+		dirs = []bool{true, false}
+	default:
+		return "", fmt.Errorf("unknown direction %q (expected outbound, inbound or both)", t.Direction)
+	}
+
+	var perDir []string
+	for _, outbound := range dirs {
+		// Remote is the destination of outbound packets and the source of inbound ones.
+		remoteSide, localSide := "Dst", "Src"
+		clauses := []string{"outbound"}
+		if !outbound {
+			remoteSide, localSide = "Src", "Dst"
+			clauses = []string{"inbound"}
+		}
+		if len(protos) > 0 {
+			clauses = append(clauses, anyOf(protos))
+		}
+		if len(remote) > 0 {
+			clauses = append(clauses, portClause(protos, remoteSide, remote))
+		}
+		if len(local) > 0 {
+			clauses = append(clauses, portClause(protos, localSide, local))
+		}
+		if len(dest) > 0 {
+			clauses = append(clauses, addrClause(remoteSide, dest))
+		}
+		perDir = append(perDir, strings.Join(clauses, " and "))
+	}
+
+	parts := []string{}
+	if base != "" {
+		parts = append(parts, "("+base+")")
+	}
+	if len(perDir) == 1 {
+		parts = append(parts, perDir[0])
+	} else {
+		parts = append(parts, "(("+strings.Join(perDir, ") or (")+"))")
+	}
+	if len(excludePorts) > 0 {
+		parts = append(parts, "not "+anyOf([]string{portClause(protos, "Src", excludePorts), portClause(protos, "Dst", excludePorts)}))
+	}
+	if len(excludeIPs) > 0 {
+		parts = append(parts, "not "+anyOf([]string{addrClause("Src", excludeIPs), addrClause("Dst", excludeIPs)}))
+	}
+	return strings.Join(parts, " and "), nil
+}
+
+// targetProtocols maps --protocol to WinDivert protocol keywords; nil means any.
+func targetProtocols(p string) ([]string, error) {
+	switch strings.ToLower(p) {
+	case "", "all":
+		return nil, nil
+	case "tcp":
+		return []string{"tcp"}, nil
+	case "udp":
+		return []string{"udp"}, nil
+	case "icmp":
+		return []string{"icmp", "icmpv6"}, nil
+	default:
+		return nil, fmt.Errorf("unknown protocol %q (expected tcp, udp, icmp or all)", p)
+	}
+}
+
+func hasPortProtocol(protos []string) bool {
+	if protos == nil {
+		return true
+	}
+	for _, p := range protos {
+		if p == "tcp" || p == "udp" {
+			return true
+		}
+	}
+	return false
+}
+
+// portClause matches side ("Src" or "Dst") ports of the TCP/UDP protocols in protos.
+func portClause(protos []string, side string, ports []portRange) string {
+	if protos == nil {
+		protos = []string{"tcp", "udp"}
+	}
+	var alts []string
+	for _, proto := range protos {
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		field := proto + "." + side + "Port"
+		for _, r := range ports {
+			if r.lo == r.hi {
+				alts = append(alts, fmt.Sprintf("%s == %d", field, r.lo))
+			} else {
+				alts = append(alts, fmt.Sprintf("(%s >= %d and %s <= %d)", field, r.lo, field, r.hi))
+			}
+		}
+	}
+	return anyOf(alts)
+}
+
+// addrClause matches side addresses against prefixes, using ip.* or ipv6.* fields as
+// appropriate; a prefix becomes an inclusive address range.
+func addrClause(side string, prefixes []netip.Prefix) string {
+	var alts []string
+	for _, p := range prefixes {
+		field := "ip." + side + "Addr"
+		if p.Addr().Is6() {
+			field = "ipv6." + side + "Addr"
+		}
+		first, last := prefixRange(p)
+		if first == last {
+			alts = append(alts, fmt.Sprintf("%s == %s", field, first))
+		} else {
+			alts = append(alts, fmt.Sprintf("(%s >= %s and %s <= %s)", field, first, field, last))
+		}
+	}
+	return anyOf(alts)
+}
+
+// prefixRange returns the first and last address of p.
+func prefixRange(p netip.Prefix) (netip.Addr, netip.Addr) {
+	p = p.Masked()
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	last, _ := netip.AddrFromSlice(b)
+	return p.Addr(), last
+}
+
+// anyOf joins alternatives with "or", parenthesized when there is more than one.
+func anyOf(alts []string) string {
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	return "(" + strings.Join(alts, " or ") + ")"
+}
+
+func parsePortList(s string) ([]portRange, error) {
+	var out []portRange
+	for _, item := range splitList(s) {
+		lo, hi, isRange := strings.Cut(item, "-")
+		a, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		b := a
+		if isRange {
+			if b, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if b < a {
+				return nil, fmt.Errorf("port range %q is reversed", item)
+			}
+		}
+		out = append(out, portRange{a, b})
+	}
+	return out, nil
+}
+
+func parsePort(s string) (uint16, error) {
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return uint16(v), nil
+}
+
+func parsePrefixList(s string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, item := range splitList(s) {
+		if strings.Contains(item, "/") {
+			p, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", item)
+			}
+			out = append(out, p)
+			continue
+		}
+		a, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q", item)
+		}
+		out = append(out, netip.PrefixFrom(a, a.BitLen()))
+	}
+	return out, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,264 @@
+package exec
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"chaosblade-win/internal/filter"
+	"chaosblade-win/internal/packet"
+)
+
+// targetPacket is a packet to match a compiled target filter against.
+type targetPacket struct {
+	proto     packet.Protocol
+	src, dst  string
+	sport     uint16
+	dport     uint16
+	outbound  bool
+	wantMatch bool
+}
+
+// build encodes the packet with an empty transport payload.
+func (tp targetPacket) build(t *testing.T) []byte {
+	t.Helper()
+	src, dst := netip.MustParseAddr(tp.src), netip.MustParseAddr(tp.dst)
+	proto := tp.proto
+	if proto == packet.ProtoICMP && src.Is6() {
+		proto = packet.ProtoICMPv6
+	}
+	var l4 []byte
+	switch proto {
+	case packet.ProtoTCP:
+		l4 = make([]byte, 20)
+		l4[12], l4[13] = 5<<4, byte(packet.TCPAck)
+	case packet.ProtoUDP:
+		l4 = make([]byte, 8)
+		binary.BigEndian.PutUint16(l4[4:], 8)
+	default:
+		l4 = make([]byte, 8)
+		l4[0] = 8
+		if proto == packet.ProtoICMPv6 {
+			l4[0] = 128
+		}
+	}
+	if proto == packet.ProtoTCP || proto == packet.ProtoUDP {
+		binary.BigEndian.PutUint16(l4[0:], tp.sport)
+		binary.BigEndian.PutUint16(l4[2:], tp.dport)
+	}
+
+	var b []byte
+	if src.Is4() {
+		b = make([]byte, 20, 20+len(l4))
+		b[0] = 0x45
+		binary.BigEndian.PutUint16(b[2:], uint16(20+len(l4)))
+		b[8], b[9] = 64, byte(proto)
+		copy(b[12:16], src.AsSlice())
+		copy(b[16:20], dst.AsSlice())
+	} else {
+		b = make([]byte, 40, 40+len(l4))
+		b[0] = 0x60
+		binary.BigEndian.PutUint16(b[4:], uint16(len(l4)))
+		b[6], b[7] = byte(proto), 64
+		copy(b[8:24], src.AsSlice())
+		copy(b[24:40], dst.AsSlice())
+	}
+	b = append(b, l4...)
+	p, err := packet.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RecomputeChecksums()
+	return b
+}
+
+const (
+	localV4  = "192.0.2.1"
+	remoteV4 = "198.51.100.7"
+	localV6  = "2001:db8:1::1"
+	remoteV6 = "2001:db8:2::7"
+)
+
+// outTCP and inTCP build TCP packets between the local and a remote host, sending to or
+// receiving from remotePort.
+func outTCP(remote string, localPort, remotePort uint16, want bool) targetPacket {
+	local := localV4
+	if strings.Contains(remote, ":") {
+		local = localV6
+	}
+	return targetPacket{packet.ProtoTCP, local, remote, localPort, remotePort, true, want}
+}
+
+func inTCP(remote string, localPort, remotePort uint16, want bool) targetPacket {
+	local := localV4
+	if strings.Contains(remote, ":") {
+		local = localV6
+	}
+	return targetPacket{packet.ProtoTCP, remote, local, remotePort, localPort, false, want}
+}
+
+func withProto(p packet.Protocol, tp targetPacket) targetPacket {
+	tp.proto = p
+	return tp
+}
+
+func TestNetTargetFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		target NetTarget
+		base   string
+		pkts   []targetPacket
+	}{
+		{"remote port", NetTarget{RemotePorts: "80", Protocol: "tcp"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 80, true),
+			outTCP(remoteV4, 50000, 81, false),
+			outTCP(remoteV4, 80, 50000, false),
+			inTCP(remoteV4, 50000, 80, false),
+			withProto(packet.ProtoUDP, outTCP(remoteV4, 50000, 80, false)),
+		}},
+		{"remote port any transport", NetTarget{RemotePorts: "53"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 53, true),
+			withProto(packet.ProtoUDP, outTCP(remoteV4, 50000, 53, true)),
+			withProto(packet.ProtoICMP, outTCP(remoteV4, 0, 0, false)),
+		}},
+		{"port range", NetTarget{RemotePorts: "443,8000-8080", Protocol: "tcp"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 443, true),
+			outTCP(remoteV4, 50000, 7999, false),
+			outTCP(remoteV4, 50000, 8000, true),
+			outTCP(remoteV4, 50000, 8042, true),
+			outTCP(remoteV4, 50000, 8080, true),
+			outTCP(remoteV4, 50000, 8081, false),
+		}},
+		{"local port", NetTarget{LocalPorts: "22", Protocol: "tcp", Direction: "inbound"}, "", []targetPacket{
+			inTCP(remoteV4, 22, 50000, true),
+			inTCP(remoteV4, 50000, 22, false),
+			outTCP(remoteV4, 22, 50000, false),
+		}},
+		{"both directions swap sides", NetTarget{RemotePorts: "443", LocalPorts: "50000-50010", Protocol: "tcp", Direction: "both"}, "", []targetPacket{
+			outTCP(remoteV4, 50005, 443, true),
+			inTCP(remoteV4, 50005, 443, true),
+			outTCP(remoteV4, 443, 50005, false),
+			inTCP(remoteV4, 443, 50005, false),
+			outTCP(remoteV4, 50011, 443, false),
+		}},
+		{"both directions swap addresses", NetTarget{DestinationIP: remoteV4, Direction: "both"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 80, true),
+			inTCP(remoteV4, 50000, 80, true),
+			outTCP("198.51.100.8", 50000, 80, false),
+			inTCP("198.51.100.8", 50000, 80, false),
+		}},
+		{"single address is /32", NetTarget{DestinationIP: remoteV4 + "/32"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 80, true),
+			outTCP("198.51.100.6", 50000, 80, false),
+			outTCP("198.51.100.8", 50000, 80, false),
+		}},
+		{"all of IPv4", NetTarget{DestinationIP: "0.0.0.0/0"}, "", []targetPacket{
+			outTCP("0.0.0.1", 50000, 80, true),
+			outTCP("255.255.255.254", 50000, 80, true),
+			outTCP(remoteV6, 50000, 80, false),
+		}},
+		{"all of IPv6", NetTarget{DestinationIP: "::/0"}, "", []targetPacket{
+			outTCP(remoteV6, 50000, 80, true),
+			outTCP("ffff::1", 50000, 80, true),
+			outTCP(remoteV4, 50000, 80, false),
+		}},
+		{"mixed CIDR list", NetTarget{DestinationIP: "198.51.100.0/24, 2001:db8:2::/48"}, "", []targetPacket{
+			outTCP("198.51.100.0", 50000, 80, true),
+			outTCP("198.51.100.255", 50000, 80, true),
+			outTCP("198.51.101.0", 50000, 80, false),
+			outTCP("2001:db8:2:ffff::1", 50000, 80, true),
+			outTCP("2001:db8:3::1", 50000, 80, false),
+		}},
+		{"unmasked CIDR", NetTarget{DestinationIP: "198.51.100.77/30"}, "", []targetPacket{
+			outTCP("198.51.100.76", 50000, 80, true),
+			outTCP("198.51.100.79", 50000, 80, true),
+			outTCP("198.51.100.80", 50000, 80, false),
+		}},
+		{"icmp", NetTarget{Protocol: "icmp"}, "", []targetPacket{
+			withProto(packet.ProtoICMP, outTCP(remoteV4, 0, 0, true)),
+			withProto(packet.ProtoICMP, outTCP(remoteV6, 0, 0, true)),
+			outTCP(remoteV4, 50000, 80, false),
+		}},
+		{"exclude port either side", NetTarget{ExcludePorts: "22", Protocol: "tcp", Direction: "both"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 80, true),
+			outTCP(remoteV4, 50000, 22, false),
+			outTCP(remoteV4, 22, 50000, false),
+			inTCP(remoteV4, 22, 50000, false),
+			inTCP(remoteV4, 50000, 443, true),
+		}},
+		{"exclude port keeps other protocols", NetTarget{ExcludePorts: "53"}, "", []targetPacket{
+			withProto(packet.ProtoUDP, outTCP(remoteV4, 50000, 53, false)),
+			withProto(packet.ProtoICMP, outTCP(remoteV4, 0, 0, true)),
+		}},
+		{"exclude ip either side", NetTarget{ExcludeIPs: "198.51.100.0/24,2001:db8:2::7", Direction: "both"}, "", []targetPacket{
+			outTCP(remoteV4, 50000, 80, false),
+			inTCP(remoteV4, 50000, 80, false),
+			outTCP("203.0.113.9", 50000, 80, true),
+			outTCP(remoteV6, 50000, 80, false),
+			outTCP("2001:db8:2::8", 50000, 80, true),
+		}},
+		{"base expression", NetTarget{RemotePorts: "80", Protocol: "tcp"}, "tcp.Syn", []targetPacket{
+			outTCP(remoteV4, 50000, 80, false),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := tt.target.Filter(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := filter.Parse(expr)
+			if err != nil {
+				t.Fatalf("%s: %v", expr, err)
+			}
+			for _, tp := range tt.pkts {
+				if got := f.Match(tp.build(t), filter.Meta{Outbound: tp.outbound}); got != tp.wantMatch {
+					t.Errorf("%s %s:%d -> %s:%d (outbound %v) matched = %v, want %v\n\tfilter: %s",
+						tp.proto, tp.src, tp.sport, tp.dst, tp.dport, tp.outbound, got, tp.wantMatch, expr)
+				}
+			}
+		})
+	}
+}
+
+func TestNetTargetFilterErrors(t *testing.T) {
+	tests := []struct {
+		target NetTarget
+		want   string
+	}{
+		{NetTarget{RemotePorts: "80", Protocol: "icmp"}, "ports need protocol tcp or udp"},
+		{NetTarget{ExcludePorts: "22", Protocol: "icmp"}, "ports need protocol tcp or udp"},
+		{NetTarget{RemotePorts: "8080-8000"}, `remote-port: port range "8080-8000" is reversed`},
+		{NetTarget{LocalPorts: "70000"}, "local-port: invalid port"},
+		{NetTarget{DestinationIP: "10.0.0.0/33"}, "destination-ip: invalid CIDR"},
+		{NetTarget{ExcludeIPs: "example.com"}, "exclude-ip: invalid IP"},
+		{NetTarget{Protocol: "sctp"}, "unknown protocol"},
+		{NetTarget{Direction: "sideways"}, "unknown direction"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.target.Filter(""); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: Filter error = %v, want %q", tt.target, err, tt.want)
+		}
+	}
+}
+
+func TestPrefixRange(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		first, last string
+	}{
+		{"0.0.0.0/0", "0.0.0.0", "255.255.255.255"},
+		{"10.1.2.3/32", "10.1.2.3", "10.1.2.3"},
+		{"10.1.2.3/8", "10.0.0.0", "10.255.255.255"},
+		{"::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1"},
+		{"2001:db8::1/33", "2001:db8::", "2001:db8:7fff:ffff:ffff:ffff:ffff:ffff"},
+	}
+	for _, tt := range tests {
+		first, last := prefixRange(netip.MustParsePrefix(tt.prefix))
+		if first.String() != tt.first || last.String() != tt.last {
+			t.Errorf("prefixRange(%s) = %s-%s, want %s-%s", tt.prefix, first, last, tt.first, tt.last)
+		}
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
-	"syscall"
+	"sync/atomic"
 	"time"
-	"unsafe"
+
+	"chaosblade-win/internal/filter"
 )
 
 // ErrWinDivertMissing indicates WinDivert driver/runtime is not available.
@@ -20,9 +22,83 @@ type NetworkDelayRunner struct {
 	LossPercent   float64
 	BandwidthKbps int
 	Filter        string
+	// MaxQueued bounds how many packets may wait for release; packets arriving while
+	// the queue is full are dropped and counted as overflow.
+	MaxQueued int
+	// BurstBytes, QueueLimitPackets, QueueLimitBytes and QueuePolicy configure the
+	// bandwidth shaper used when BandwidthKbps > 0. Each direction gets its own bucket
+	// and queue.
+	BurstBytes        int64
+	QueueLimitPackets int
+	QueueLimitBytes   int64
+	QueuePolicy       QueuePolicy
+	// Distribution shapes jitter around DelayMillis (uniform when nil), and
+	// DelayCorrelation (0-100) correlates successive delays as in netem.
+	Distribution     *DelayDistribution
+	DelayCorrelation float64
+	// LossModel decides which packets are dropped; when nil, packets are dropped
+	// independently with LossPercent.
+	LossModel LossModel
+	// CorruptPercent of packets get one bit flipped as selected by CorruptMode.
+	CorruptPercent float64
+	CorruptMode    CorruptMode
+	// DuplicatePercent of packets are sent twice.
+	DuplicatePercent float64
+	// ReorderPercent of packets are held back until ReorderGap later packets in the
+	// same direction have been sent.
+	ReorderPercent float64
+	ReorderGap     int
+	// Seed makes the random choices (loss, corruption, duplication, reordering and
+	// jitter) reproducible; 0 seeds from the clock.
+	Seed int64
+	// Device supplies and reinjects packets; when nil, Run opens WinDivert with Filter.
+	// Other devices have Filter applied in process, and non-matching packets bypass the
+	// runner untouched.
+	Device PacketDevice
+
+	outShaper atomic.Pointer[shaper]
+	inShaper  atomic.Pointer[shaper]
+
+	received   atomic.Int64
+	sent       atomic.Int64
+	lost       atomic.Int64
+	overflow   atomic.Int64
+	corrupted  atomic.Int64
+	duplicated atomic.Int64
+	reordered  atomic.Int64
+	bypassed   atomic.Int64
+	queue      atomic.Pointer[delayQueue]
+}
+
+// NetworkStats is a snapshot of a NetworkDelayRunner's packet counters.
+type NetworkStats struct {
+	Received   int64 // packets read from the device
+	Sent       int64 // packets reinjected
+	Lost       int64 // packets dropped by the loss model
+	Overflow   int64 // packets dropped because the delay queue was full
+	Corrupted  int64 // packets with a flipped bit
+	Duplicated int64 // extra copies sent
+	Reordered  int64 // packets sent behind later ones
+	Bypassed   int64 // packets passed through because they did not match Filter
+	Queued     int   // packets currently waiting for release
+
+	// Outbound and Inbound describe the bandwidth shaper queues, when shaping.
+	Outbound ShaperStats
+	Inbound  ShaperStats
 }
 
-const defaultNetFilter = "outbound and tcp"
+const (
+	defaultNetFilter = "outbound and tcp"
+	// defaultNetMaxQueued bounds the delay queue; at 1500-byte packets it holds ~15 MB.
+	defaultNetMaxQueued = 10000
+	// defaultNetQueueLimit is the shaper queue length in packets when none is set.
+	defaultNetQueueLimit = 1000
+	// defaultNetReorderGap is how many packets overtake a reordered one by default.
+	defaultNetReorderGap = 5
+	// reorderMaxHold bounds how long a reordered packet waits for later traffic, so a
+	// quiet flow does not strand it.
+	reorderMaxHold = 200 * time.Millisecond
+)
 
 // NewNetworkDelayRunner creates a runner with given shaping parameters.
 func NewNetworkDelayRunner(delayMillis, jitterMillis int, lossPercent float64, filter string, bandwidthKbps int) *NetworkDelayRunner {
@@ -32,225 +108,263 @@ func NewNetworkDelayRunner(delayMillis, jitterMillis int, lossPercent float64, f
 		LossPercent:   lossPercent,
 		BandwidthKbps: bandwidthKbps,
 		Filter:        filter,
+		MaxQueued:     defaultNetMaxQueued,
+		QueuePolicy:   QueueTailDrop,
+		CorruptMode:   CorruptPayload,
+		ReorderGap:    defaultNetReorderGap,
+	}
+}
+
+// Stats returns the current packet counters.
+func (r *NetworkDelayRunner) Stats() NetworkStats {
+	s := NetworkStats{
+		Received:   r.received.Load(),
+		Sent:       r.sent.Load(),
+		Lost:       r.lost.Load(),
+		Overflow:   r.overflow.Load(),
+		Corrupted:  r.corrupted.Load(),
+		Duplicated: r.duplicated.Load(),
+		Reordered:  r.reordered.Load(),
+		Bypassed:   r.bypassed.Load(),
+	}
+	if q := r.queue.Load(); q != nil {
+		s.Queued = q.len()
 	}
+	now := time.Now()
+	if sh := r.outShaper.Load(); sh != nil {
+		s.Outbound = sh.stats(now)
+	}
+	if sh := r.inShaper.Load(); sh != nil {
+		s.Inbound = sh.stats(now)
+	}
+	return s
 }
 
-// Run applies delay/loss/bandwidth shaping using WinDivert.
+// Run applies delay/loss/bandwidth shaping to packets from Device (WinDivert by
+// default). A receive goroutine timestamps each packet with its release time and queues
+// it; a send goroutine reinjects packets as they fall due, so every packet is delayed
+// independently and throughput is not limited by the delay. Run returns nil once a
+// finite device such as a pcap replay is exhausted and its queue has drained.
 func (r *NetworkDelayRunner) Run(ctx context.Context) error {
 	if r.DelayMillis < 0 || r.JitterMillis < 0 || r.LossPercent < 0 || r.LossPercent > 100 {
 		return fmt.Errorf("invalid network params: delay=%d jitter=%d loss=%.2f", r.DelayMillis, r.JitterMillis, r.LossPercent)
 	}
+	for name, p := range map[string]float64{"corrupt": r.CorruptPercent, "duplicate": r.DuplicatePercent, "reorder": r.ReorderPercent} {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("invalid %s percent %.2f", name, p)
+		}
+	}
+	if r.DelayCorrelation < 0 || r.DelayCorrelation > 100 {
+		return fmt.Errorf("invalid delay correlation %.2f", r.DelayCorrelation)
+	}
+	if r.ReorderGap <= 0 {
+		r.ReorderGap = defaultNetReorderGap
+	}
+	if r.LossModel == nil {
+		r.LossModel = &bernoulliLoss{p: r.LossPercent / 100}
+	}
 
 	if r.Filter == "" {
 		r.Filter = defaultNetFilter
 	}
-
-	if err := loadWinDivert(); err != nil {
-		return err
+	if r.MaxQueued <= 0 {
+		r.MaxQueued = defaultNetMaxQueued
 	}
 
-	handle, err := winDivertOpen(r.Filter)
-	if err != nil {
-		if errors.Is(err, syscall.ERROR_FILE_NOT_FOUND) {
-			return ErrWinDivertMissing
+	// WinDivert compiles the filter itself and is the authority on what it accepts;
+	// other devices need it parsed here to apply it.
+	dev := r.Device
+	if dev == nil {
+		var err error
+		dev, err = OpenWinDivertDevice(r.Filter)
+		if err != nil {
+			return err
 		}
-		return err
+	} else {
+		f, err := filter.Parse(r.Filter)
+		if err != nil {
+			return err
+		}
+		dev = newFilteredDevice(dev, f, &r.bypassed)
 	}
-	defer winDivertClose(handle)
+	defer dev.Close()
+
+	q := newDelayQueue(r.MaxQueued)
+	r.queue.Store(q)
 
-	// Ensure any blocking recv is released when context ends.
+	// Ensure any blocking recv is released when context ends, and send what is still
+	// queued right away.
 	go func() {
 		<-ctx.Done()
-		_ = winDivertShutdown(handle)
+		_ = dev.Shutdown()
+		q.flush()
 	}()
 
+	sendErr := make(chan error, 1)
+	go func() {
+		err := r.sendLoop(dev, q)
+		if err != nil {
+			// Stop receiving too; there is no way to deliver further packets.
+			_ = dev.Shutdown()
+		}
+		sendErr <- err
+	}()
+
+	recvErr := r.recvLoop(ctx, dev, q)
+	// sendLoop returns once the closed queue is empty, so nothing received is lost.
+	q.close()
+	if err := <-sendErr; err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return recvErr
+}
+
+// recvLoop reads packets, applies loss, corruption, duplication and bandwidth shaping,
+// and queues survivors with their release time, marking some for reordering.
+func (r *NetworkDelayRunner) recvLoop(ctx context.Context, dev PacketDevice, q *delayQueue) error {
 	pktBuf := make([]byte, 1<<16) // 64 KiB for packet payloads
-	addrBuf := make([]byte, 128)  // WINDIVERT_ADDRESS opaque storage (use larger buffer to be safe)
 
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	baseDelay := time.Duration(r.DelayMillis) * time.Millisecond
-	jitter := time.Duration(r.JitterMillis) * time.Millisecond
+	seed := r.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	delays := newDelaySampler(time.Duration(r.DelayMillis)*time.Millisecond, time.Duration(r.JitterMillis)*time.Millisecond, r.Distribution, r.DelayCorrelation/100)
 
-	var rateBytesPerSec float64
 	if r.BandwidthKbps > 0 {
-		rateBytesPerSec = float64(r.BandwidthKbps) * 1000.0 / 8.0
+		rate := int64(r.BandwidthKbps) * 1000 / 8
+		limitPackets, limitBytes := r.QueueLimitPackets, r.QueueLimitBytes
+		if limitPackets <= 0 && limitBytes <= 0 {
+			limitPackets = defaultNetQueueLimit
+		}
+		r.outShaper.Store(newShaper(rate, r.BurstBytes, limitPackets, limitBytes, r.QueuePolicy, rng))
+		r.inShaper.Store(newShaper(rate, r.BurstBytes, limitPackets, limitBytes, r.QueuePolicy, rng))
 	}
 
-	timer := time.NewTimer(time.Hour)
-	timer.Stop()
-
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		n, addrLen, recvErr := winDivertRecv(handle, pktBuf, addrBuf)
-		if recvErr != nil {
-			if ctx.Err() != nil {
+		n, addr, err := dev.Recv(pktBuf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, ErrPacketDeviceShutdown) {
 				return ctx.Err()
 			}
-			return recvErr
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
 		}
+		r.received.Add(1)
 
-		if r.LossPercent > 0 && rng.Float64()*100.0 < r.LossPercent {
+		if r.LossModel.Drop(rng) {
+			r.lost.Add(1)
 			continue
 		}
-
-		delay := baseDelay
-		if jitter > 0 {
-			// Uniform jitter in [-jitter, +jitter].
-			offset := time.Duration(rng.Int63n(int64(jitter)*2)) - jitter
-			delay += offset
-			if delay < 0 {
-				delay = 0
+		if r.CorruptPercent > 0 && rng.Float64()*100.0 < r.CorruptPercent {
+			if corruptPacket(pktBuf[:n], &addr, r.CorruptMode, rng) {
+				r.corrupted.Add(1)
 			}
 		}
 
-		if rateBytesPerSec > 0 {
-			bwDelay := time.Duration(float64(n) * float64(time.Second) / rateBytesPerSec)
-			delay += bwDelay
+		copies := 1
+		if r.DuplicatePercent > 0 && rng.Float64()*100.0 < r.DuplicatePercent {
+			copies = 2
 		}
-
-		if delay > 0 {
-			timer.Reset(delay)
-			select {
-			case <-ctx.Done():
-				timer.Stop()
-				return ctx.Err()
-			case <-timer.C:
+		for c := 0; c < copies; c++ {
+			p := &queuedPacket{data: append([]byte(nil), pktBuf[:n]...), addr: addr}
+			if r.ReorderPercent > 0 && rng.Float64()*100.0 < r.ReorderPercent {
+				p.holdFor = r.ReorderGap
 			}
-		}
-
-		if err := winDivertSend(handle, pktBuf[:n], addrBuf[:addrLen]); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
+			if !r.schedule(p, delays.next(rng)) {
+				continue
+			}
+			if !q.push(p) {
+				r.overflow.Add(1)
+				continue
+			}
+			if c > 0 {
+				r.duplicated.Add(1)
 			}
-			return err
-		}
-	}
-}
-
-// --- Minimal WinDivert binding ---
-
-const (
-	winDivertLayerNetwork = 0
-	winDivertShutdownBoth = 2
-)
-
-var (
-	winDivertDLL          = syscall.NewLazyDLL("WinDivert.dll")
-	procWinDivertOpen     = winDivertDLL.NewProc("WinDivertOpen")
-	procWinDivertRecv     = winDivertDLL.NewProc("WinDivertRecv")
-	procWinDivertSend     = winDivertDLL.NewProc("WinDivertSend")
-	procWinDivertClose    = winDivertDLL.NewProc("WinDivertClose")
-	procWinDivertShutdown = winDivertDLL.NewProc("WinDivertShutdown")
-)
-
-func loadWinDivert() error {
-	if err := winDivertDLL.Load(); err != nil {
-		if errors.Is(err, syscall.ERROR_MOD_NOT_FOUND) || errors.Is(err, syscall.Errno(193)) {
-			// missing DLL or wrong arch
-			return ErrWinDivertMissing
 		}
-		return err
 	}
-	return nil
 }
 
-func winDivertOpen(filter string) (syscall.Handle, error) {
-	filterPtr, err := syscall.BytePtrFromString(filter)
-	if err != nil {
-		return 0, err
+// schedule sets p's release time from the bandwidth shaper and delay, reporting false
+// when the shaper drops it.
+func (r *NetworkDelayRunner) schedule(p *queuedPacket, delay time.Duration) bool {
+	now := time.Now()
+	departure := now
+	sh := r.inShaper.Load()
+	if p.addr.Outbound {
+		sh = r.outShaper.Load()
 	}
-
-	h, _, callErr := procWinDivertOpen.Call(
-		uintptr(unsafe.Pointer(filterPtr)),
-		uintptr(winDivertLayerNetwork),
-		uintptr(int16(0)),
-		uintptr(uint64(0)),
-	)
-	// WinDivertOpen returns NULL or INVALID_HANDLE_VALUE (-1) on failure
-	if h == 0 || h == ^uintptr(0) {
-		// Return a more detailed error including the underlying syscall error
-		if callErr != nil {
-			if errno, ok := callErr.(syscall.Errno); ok {
-				return 0, fmt.Errorf("WinDivertOpen failed: %w (errno=%d)", errno, int(errno))
-			}
-			return 0, fmt.Errorf("WinDivertOpen failed: %v", callErr)
+	if sh != nil {
+		var ok bool
+		if departure, ok = sh.admit(now, len(p.data)); !ok {
+			return false
 		}
-		return 0, fmt.Errorf("WinDivertOpen failed: unknown error (handle invalid)")
 	}
-	// Debug: print handle value
-	fmt.Printf("DEBUG: WinDivertOpen returned handle=0x%X\n", h)
-	return syscall.Handle(h), nil
-}
 
-func winDivertRecv(h syscall.Handle, pktBuf []byte, addrBuf []byte) (int, int, error) {
-	var recvLen uint64
-	addrLen := uint32(len(addrBuf))
-
-	// Debug: print buffer sizes before call
-	fmt.Printf("DEBUG: WinDivertRecv called with handle=0x%X pktBufLen=%d addrBufLen=%d\n", h, len(pktBuf), len(addrBuf))
+	// Propagation delay starts once the packet has left the bottleneck link.
+	p.release = departure.Add(delay)
+	return true
+}
 
-	r1, _, err := procWinDivertRecv.Call(
-		uintptr(h),
-		uintptr(unsafe.Pointer(&pktBuf[0])),
-		uintptr(len(pktBuf)),
-		uintptr(unsafe.Pointer(&recvLen)),
-		uintptr(unsafe.Pointer(&addrBuf[0])),
-		uintptr(unsafe.Pointer(&addrLen)),
-		uintptr(uint64(0)),
-	)
-	if r1 == 0 {
-		if err != nil {
-			if errno, ok := err.(syscall.Errno); ok {
-				return 0, 0, fmt.Errorf("WinDivertRecv failed: %w (errno=%d)", errno, int(errno))
-			}
-			return 0, 0, fmt.Errorf("WinDivertRecv failed: %v", err)
+// sendLoop reinjects packets as they fall due. A packet marked for reordering is held
+// back when it falls due and sent once holdFor later packets in the same direction have
+// gone out, or after reorderMaxHold. When the queue is flushed, packets still queued or
+// held are released immediately rather than dropped.
+func (r *NetworkDelayRunner) sendLoop(dev PacketDevice, q *delayQueue) error {
+	var held []*queuedPacket
+	send := func(p *queuedPacket) error {
+		if err := dev.Send(p.data, p.addr); err != nil {
+			return err
 		}
-		return 0, 0, fmt.Errorf("WinDivertRecv failed: unknown error (r1==0)")
+		p.sent = true
+		r.sent.Add(1)
+		return nil
 	}
-	return int(recvLen), int(addrLen), nil
-}
 
-func winDivertSend(h syscall.Handle, pkt []byte, addr []byte) error {
-	var sendLen uint64
-	addrLen := uint32(len(addr))
+	for {
+		p := q.next()
+		if p == nil {
+			break
+		}
+		if p.sent {
+			continue // a held packet already released by later traffic
+		}
+		if p.holdFor > 0 && !p.holding {
+			// Requeue with the hold deadline so the packet is still sent if no later
+			// packets arrive.
+			p.holding = true
+			p.release = time.Now().Add(reorderMaxHold)
+			q.requeue(p)
+			held = append(held, p)
+			continue
+		}
+		if err := send(p); err != nil {
+			return err
+		}
 
-	r1, _, err := procWinDivertSend.Call(
-		uintptr(h),
-		uintptr(unsafe.Pointer(&pkt[0])),
-		uintptr(len(pkt)),
-		uintptr(unsafe.Pointer(&sendLen)),
-		uintptr(unsafe.Pointer(&addr[0])),
-		uintptr(unsafe.Pointer(&addrLen)),
-		uintptr(uint64(0)),
-	)
-	if r1 == 0 {
-		if err != nil {
-			if errno, ok := err.(syscall.Errno); ok {
-				return fmt.Errorf("WinDivertSend failed: %w (errno=%d)", errno, int(errno))
+		kept := held[:0]
+		for _, h := range held {
+			switch {
+			case h.sent:
+			case h.addr.Outbound == p.addr.Outbound && !p.holding:
+				if h.holdFor--; h.holdFor == 0 {
+					if err := send(h); err != nil {
+						return err
+					}
+					r.reordered.Add(1)
+					continue
+				}
+				kept = append(kept, h)
+			default:
+				kept = append(kept, h)
 			}
-			return fmt.Errorf("WinDivertSend failed: %v", err)
 		}
-		return fmt.Errorf("WinDivertSend failed: unknown error (r1==0)")
-	}
-	if int(sendLen) != len(pkt) {
-		return fmt.Errorf("partial send: %d/%d", sendLen, len(pkt))
+		held = kept
 	}
 	return nil
 }
-
-func winDivertShutdown(h syscall.Handle) error {
-	r1, _, err := procWinDivertShutdown.Call(uintptr(h), uintptr(winDivertShutdownBoth))
-	if r1 == 0 && err != nil {
-		return err
-	}
-	return nil
-}
-
-func winDivertClose(h syscall.Handle) {
-	procWinDivertClose.Call(uintptr(h))
-}
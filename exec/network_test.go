@@ -0,0 +1,375 @@
+package exec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"chaosblade-win/internal/packet"
+)
+
+// testUDPPacket builds an outbound IPv4 UDP packet of size bytes whose IP ID is id, so
+// tests can tell packets apart after they pass through a runner.
+func testUDPPacket(t testing.TB, id uint16, size int) []byte {
+	t.Helper()
+	b := make([]byte, size)
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:], uint16(size))
+	binary.BigEndian.PutUint16(b[4:], id)
+	b[8], b[9] = 64, byte(packet.ProtoUDP)
+	copy(b[12:16], []byte{10, 0, 0, 1})
+	copy(b[16:20], []byte{10, 0, 0, 2})
+	binary.BigEndian.PutUint16(b[20:], 40000)
+	binary.BigEndian.PutUint16(b[22:], 53)
+	binary.BigEndian.PutUint16(b[24:], uint16(size-20))
+	p, err := packet.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RecomputeChecksums()
+	return b
+}
+
+func packetID(b []byte) uint16 {
+	return binary.BigEndian.Uint16(b[4:])
+}
+
+// runMemory pushes count packets of size bytes through r on a memory device, all
+// injected at once, and returns what was sent once the runner drains.
+func runMemory(t *testing.T, r *NetworkDelayRunner, count, size int) []SentPacket {
+	t.Helper()
+	dev := NewMemoryPacketDevice()
+	for i := 0; i < count; i++ {
+		dev.Inject(testUDPPacket(t, uint16(i), size), PacketAddress{Outbound: true})
+	}
+	dev.EndInput()
+	r.Device = dev
+	if r.Filter == "" {
+		r.Filter = "outbound and udp"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return dev.Sent()
+}
+
+func TestMemoryPacketDevice(t *testing.T) {
+	dev := NewMemoryPacketDevice()
+	dev.Inject(testUDPPacket(t, 1, 60), PacketAddress{Outbound: true})
+	dev.EndInput()
+
+	buf := make([]byte, 1500)
+	n, addr, err := dev.Recv(buf)
+	if err != nil || n != 60 || packetID(buf[:n]) != 1 || !addr.Outbound || addr.Timestamp.IsZero() {
+		t.Fatalf("Recv = %d, %+v, %v", n, addr, err)
+	}
+	if _, _, err := dev.Recv(buf); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv after input ended = %v, want io.EOF", err)
+	}
+
+	if err := dev.Send(buf[:n], addr); err != nil {
+		t.Fatal(err)
+	}
+	if sent := dev.Sent(); len(sent) != 1 || packetID(sent[0].Data) != 1 {
+		t.Fatalf("Sent = %+v", sent)
+	}
+
+	blocked := NewMemoryPacketDevice()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := blocked.Recv(buf)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_ = blocked.Shutdown()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrPacketDeviceShutdown) {
+			t.Errorf("Recv after Shutdown = %v, want ErrPacketDeviceShutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not unblock Recv")
+	}
+}
+
+func TestNetworkDelayOrderingAndTiming(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	r := NewNetworkDelayRunner(int(delay/time.Millisecond), 0, 0, "", 0)
+	r.Seed = 1
+	sent := runMemory(t, r, 50, 100)
+
+	if len(sent) != 50 {
+		t.Fatalf("sent %d packets, want 50", len(sent))
+	}
+	for i, p := range sent {
+		if got := packetID(p.Data); got != uint16(i) {
+			t.Fatalf("packet %d sent in position %d; a fixed delay must keep order", got, i)
+		}
+		if lat := p.At.Sub(p.Addr.Timestamp); lat < delay || lat > delay+40*time.Millisecond {
+			t.Errorf("packet %d latency %v, want about %v", i, lat, delay)
+		}
+	}
+}
+
+func TestNetworkJitterBounds(t *testing.T) {
+	const delay, jitter = 40 * time.Millisecond, 20 * time.Millisecond
+	r := NewNetworkDelayRunner(int(delay/time.Millisecond), int(jitter/time.Millisecond), 0, "", 0)
+	r.Seed = 2
+	sent := runMemory(t, r, 200, 100)
+
+	if len(sent) != 200 {
+		t.Fatalf("sent %d packets, want 200", len(sent))
+	}
+	var sum time.Duration
+	reordered := false
+	for i, p := range sent {
+		lat := p.At.Sub(p.Addr.Timestamp)
+		if lat < delay-jitter || lat > delay+jitter+40*time.Millisecond {
+			t.Errorf("packet %d latency %v outside %v±%v", packetID(p.Data), lat, delay, jitter)
+		}
+		sum += lat
+		if i > 0 && packetID(p.Data) < packetID(sent[i-1].Data) {
+			reordered = true
+		}
+		if i > 0 && p.At.Before(sent[i-1].At) {
+			t.Fatalf("packets sent out of release order at position %d", i)
+		}
+	}
+	if mean := sum / time.Duration(len(sent)); mean < delay-5*time.Millisecond || mean > delay+15*time.Millisecond {
+		t.Errorf("mean latency %v, want about %v", mean, delay)
+	}
+	if !reordered {
+		t.Error("uniform jitter of ±20ms over a burst never reordered packets")
+	}
+}
+
+func TestNetworkLossRate(t *testing.T) {
+	const count, loss = 5000, 20.0
+	var runs [2][]uint16
+	for i := range runs {
+		r := NewNetworkDelayRunner(0, 0, loss, "", 0)
+		r.Seed = 7
+		sent := runMemory(t, r, count, 60)
+
+		s := r.Stats()
+		if s.Received != count || s.Sent != int64(len(sent)) || s.Lost+s.Sent != count {
+			t.Fatalf("stats = %+v with %d sent", s, len(sent))
+		}
+		if rate := float64(s.Lost) / count * 100; math.Abs(rate-loss) > 2 {
+			t.Errorf("drop rate %.2f%%, want %.0f%%", rate, loss)
+		}
+		for _, p := range sent {
+			runs[i] = append(runs[i], packetID(p.Data))
+		}
+	}
+	if !slices.Equal(runs[0], runs[1]) {
+		t.Error("the same seed dropped different packets")
+	}
+}
+
+func TestNetworkFilterBypass(t *testing.T) {
+	r := NewNetworkDelayRunner(0, 0, 100, "outbound and tcp", 0)
+	sent := runMemory(t, r, 20, 60)
+
+	if len(sent) != 20 {
+		t.Fatalf("sent %d packets, want all 20 to bypass a tcp filter", len(sent))
+	}
+	if s := r.Stats(); s.Bypassed != 20 || s.Received != 0 || s.Lost != 0 {
+		t.Errorf("stats = %+v, want 20 bypassed and none received", s)
+	}
+}
+
+// TestNetworkCancelFlushesQueue ends an experiment while packets are still delayed and
+// checks every received packet is sent at once rather than lost.
+func TestNetworkCancelFlushesQueue(t *testing.T) {
+	dev := NewMemoryPacketDevice()
+	for i := 0; i < 20; i++ {
+		dev.Inject(testUDPPacket(t, uint16(i), 60), PacketAddress{Outbound: true})
+	}
+	r := NewNetworkDelayRunner(10000, 0, 0, "outbound and udp", 0)
+	r.Device = dev
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := r.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Run = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Run took %v after cancellation", d)
+	}
+	if s := r.Stats(); s.Received != 20 || s.Sent != 20 || len(dev.Sent()) != 20 {
+		t.Errorf("stats = %+v with %d sent, want all 20 flushed", s, len(dev.Sent()))
+	}
+}
+
+func TestNetworkShaperThroughput(t *testing.T) {
+	const (
+		kbps    = 8000 // 1 MB/s
+		rate    = kbps * 1000 / 8
+		count   = 200
+		size    = 1000
+		burst   = rate / 100 // the shaper's default burst
+		wantDur = time.Duration(float64(count*size-burst) / rate * float64(time.Second))
+	)
+	r := NewNetworkDelayRunner(0, 0, 0, "", kbps)
+	r.QueueLimitPackets = count
+	start := time.Now()
+	sent := runMemory(t, r, count, size)
+
+	if len(sent) != count {
+		t.Fatalf("sent %d packets, want %d", len(sent), count)
+	}
+	for i, p := range sent {
+		if got := packetID(p.Data); got != uint16(i) {
+			t.Fatalf("shaper reordered packet %d to position %d", got, i)
+		}
+	}
+	dur := sent[len(sent)-1].At.Sub(start)
+	if dur < wantDur-10*time.Millisecond || dur > wantDur+wantDur/5+30*time.Millisecond {
+		t.Errorf("drained %d bytes in %v, want about %v at %d B/s", count*size, dur, wantDur, rate)
+	}
+}
+
+func TestNetworkShaperTailDrop(t *testing.T) {
+	const limit = 10
+	r := NewNetworkDelayRunner(0, 0, 0, "", 8000)
+	r.QueueLimitPackets = limit
+	r.QueuePolicy = QueueTailDrop
+	sent := runMemory(t, r, 100, 1000)
+
+	s := r.Stats()
+	if s.Outbound.Dropped != s.Received-int64(len(sent)) || s.Lost != 0 || s.Overflow != 0 {
+		t.Fatalf("drops not accounted to the shaper: stats = %+v with %d sent", s, len(sent))
+	}
+	// The burst (10 packets) leaves at once and the queue holds limit more; only a few
+	// can drain while the rest arrive.
+	if len(sent) < limit || len(sent) > 2*limit+10 {
+		t.Errorf("sent %d of 100 through a %d-packet queue", len(sent), limit)
+	}
+	for i := 1; i < len(sent); i++ {
+		if packetID(sent[i].Data) < packetID(sent[i-1].Data) {
+			t.Fatal("tail drop reordered the survivors")
+		}
+	}
+}
+
+func TestNetworkDuplicate(t *testing.T) {
+	const count, dup = 5000, 20.0
+	r := NewNetworkDelayRunner(0, 0, 0, "", 0)
+	r.DuplicatePercent = dup
+	r.Seed = 11
+	sent := runMemory(t, r, count, 60)
+
+	s := r.Stats()
+	if s.Duplicated != int64(len(sent)-count) || s.Sent != int64(len(sent)) {
+		t.Fatalf("stats = %+v with %d sent", s, len(sent))
+	}
+	if rate := float64(s.Duplicated) / count * 100; math.Abs(rate-dup) > 2 {
+		t.Errorf("duplicated %.2f%% of packets, want %.0f%%", rate, dup)
+	}
+	seen := make(map[uint16]int)
+	for _, p := range sent {
+		seen[packetID(p.Data)]++
+	}
+	for id := uint16(0); id < count; id++ {
+		if n := seen[id]; n != 1 && n != 2 {
+			t.Fatalf("packet %d sent %d times", id, n)
+		}
+	}
+}
+
+// overtaken returns, for each packet in send order, how many packets with a higher ID
+// were sent before it.
+func overtaken(sent []SentPacket) []int {
+	out := make([]int, len(sent))
+	for i, p := range sent {
+		for _, q := range sent[:i] {
+			if packetID(q.Data) > packetID(p.Data) {
+				out[i]++
+			}
+		}
+	}
+	return out
+}
+
+func TestNetworkReorderGap(t *testing.T) {
+	const count, gap = 400, 3
+	r := NewNetworkDelayRunner(0, 0, 0, "", 0)
+	r.ReorderPercent = 5
+	r.ReorderGap = gap
+	r.Seed = 12
+	sent := runMemory(t, r, count, 60)
+
+	if len(sent) != count {
+		t.Fatalf("sent %d packets, want %d", len(sent), count)
+	}
+	late := 0
+	for i, n := range overtaken(sent) {
+		id := packetID(sent[i].Data)
+		switch {
+		case n == gap:
+			late++
+		case n != 0 && id < count-2*gap:
+			// Only packets near the end, which run out of later traffic, are released
+			// by the hold timeout instead.
+			t.Errorf("packet %d sent %d packets late, want 0 or %d", id, n, gap)
+		}
+	}
+	if s := r.Stats(); s.Reordered != int64(late) || late < count*3/100 || late > count*7/100 {
+		t.Errorf("%d packets sent %d late, stats = %+v", late, gap, s)
+	}
+}
+
+func TestNetworkReorderMaxHold(t *testing.T) {
+	r := NewNetworkDelayRunner(0, 0, 0, "", 0)
+	r.ReorderPercent = 100
+	sent := runMemory(t, r, 1, 60)
+
+	if len(sent) != 1 {
+		t.Fatalf("sent %d packets, want 1", len(sent))
+	}
+	if lat := sent[0].At.Sub(sent[0].Addr.Timestamp); lat < reorderMaxHold || lat > reorderMaxHold+50*time.Millisecond {
+		t.Errorf("held packet released after %v, want about %v", lat, reorderMaxHold)
+	}
+	if s := r.Stats(); s.Reordered != 0 {
+		t.Errorf("stats = %+v, want no gap releases", s)
+	}
+}
+
+func TestNetworkSeedReproducible(t *testing.T) {
+	var dups, late [2][]uint16
+	for i := range 2 {
+		r := NewNetworkDelayRunner(0, 0, 0, "", 0)
+		r.DuplicatePercent = 10
+		r.ReorderPercent = 10
+		r.Seed = 13
+		sent := runMemory(t, r, 500, 60)
+
+		seen := make(map[uint16]bool)
+		for j, n := range overtaken(sent) {
+			id := packetID(sent[j].Data)
+			if seen[id] {
+				dups[i] = append(dups[i], id)
+			}
+			seen[id] = true
+			if n == r.ReorderGap {
+				late[i] = append(late[i], id)
+			}
+		}
+	}
+	if len(dups[0]) == 0 || !slices.Equal(dups[0], dups[1]) {
+		t.Errorf("duplicated packets differ between runs with the same seed: %v and %v", dups[0], dups[1])
+	}
+	if len(late[0]) == 0 || !slices.Equal(late[0], late[1]) {
+		t.Errorf("reordered packets differ between runs with the same seed: %v and %v", late[0], late[1])
+	}
+}
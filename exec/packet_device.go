@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// PacketAddress is the metadata that travels with a packet between Recv and Send.
+type PacketAddress struct {
+	Timestamp time.Time // when the device received the packet; replays use the read time, not the capture time
+	Outbound  bool
+	Loopback  bool
+	IPv6      bool
+	IfIdx     uint32
+	SubIfIdx  uint32
+	// ChecksumsValid tells the device the packet's checksums are already correct and
+	// must be sent as they are rather than left to checksum offload.
+	ChecksumsValid bool
+
+	// raw holds the device's native address encoding so a packet is reinjected exactly
+	// as it was received; devices that need none leave it empty.
+	raw []byte
+}
+
+// PacketDevice intercepts packets and reinjects them. Recv blocks until a packet is
+// available and returns io.EOF once the source is exhausted or ErrPacketDeviceShutdown
+// after Shutdown.
+type PacketDevice interface {
+	Recv(buf []byte) (int, PacketAddress, error)
+	Send(pkt []byte, addr PacketAddress) error
+	// Shutdown unblocks pending and future Recv calls; Send keeps working so queued
+	// packets can still be flushed.
+	Shutdown() error
+	Close() error
+}
+
+// ErrPacketDeviceShutdown is returned by Recv after Shutdown.
+var ErrPacketDeviceShutdown = errors.New("packet device shut down")
+
+// SentPacket is a packet a MemoryPacketDevice was asked to transmit.
+type SentPacket struct {
+	Data []byte
+	Addr PacketAddress
+	At   time.Time
+}
+
+// MemoryPacketDevice is an in-memory PacketDevice: packets queued with Inject are
+// returned by Recv, and everything passed to Send is recorded for inspection.
+type MemoryPacketDevice struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []SentPacket
+	sent     []SentPacket
+	closed   bool // no more packets will be injected; Recv returns io.EOF when drained
+	shutdown bool
+}
+
+// NewMemoryPacketDevice returns an empty in-memory device.
+func NewMemoryPacketDevice() *MemoryPacketDevice {
+	d := &MemoryPacketDevice{}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// Inject queues a packet for Recv. A zero addr.Timestamp is set to the current time.
+func (d *MemoryPacketDevice) Inject(pkt []byte, addr PacketAddress) {
+	if addr.Timestamp.IsZero() {
+		addr.Timestamp = time.Now()
+	}
+	d.mu.Lock()
+	d.pending = append(d.pending, SentPacket{Data: append([]byte(nil), pkt...), Addr: addr, At: addr.Timestamp})
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// EndInput marks the input complete so Recv returns io.EOF once it is drained.
+func (d *MemoryPacketDevice) EndInput() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// Recv returns the next injected packet, blocking until one is available.
+func (d *MemoryPacketDevice) Recv(buf []byte) (int, PacketAddress, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.pending) == 0 && !d.closed && !d.shutdown {
+		d.cond.Wait()
+	}
+	if d.shutdown {
+		return 0, PacketAddress{}, ErrPacketDeviceShutdown
+	}
+	if len(d.pending) == 0 {
+		return 0, PacketAddress{}, io.EOF
+	}
+	p := d.pending[0]
+	d.pending = d.pending[1:]
+	if len(p.Data) > len(buf) {
+		return 0, PacketAddress{}, io.ErrShortBuffer
+	}
+	return copy(buf, p.Data), p.Addr, nil
+}
+
+// Send records pkt with the time it was sent.
+func (d *MemoryPacketDevice) Send(pkt []byte, addr PacketAddress) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent = append(d.sent, SentPacket{Data: append([]byte(nil), pkt...), Addr: addr, At: time.Now()})
+	return nil
+}
+
+// Sent returns a copy of the packets sent so far, in send order.
+func (d *MemoryPacketDevice) Sent() []SentPacket {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]SentPacket(nil), d.sent...)
+}
+
+// Shutdown unblocks Recv.
+func (d *MemoryPacketDevice) Shutdown() error {
+	d.mu.Lock()
+	d.shutdown = true
+	d.mu.Unlock()
+	d.cond.Broadcast()
+	return nil
+}
+
+// Close is equivalent to Shutdown for the in-memory device.
+func (d *MemoryPacketDevice) Close() error {
+	return d.Shutdown()
+}
+
+var (
+	_ PacketDevice = (*MemoryPacketDevice)(nil)
+	_ PacketDevice = (*PcapPacketDevice)(nil)
+	_ PacketDevice = (*winDivertDevice)(nil)
+)
@@ -0,0 +1,48 @@
+package exec
+
+import (
+	"sync/atomic"
+
+	"chaosblade-win/internal/filter"
+)
+
+// filteredDevice applies a WinDivert filter to a device that does not filter for
+// itself, such as a pcap replay or the in-memory device. Packets that do not match are
+// passed straight through to Send, as WinDivert would never have intercepted them.
+type filteredDevice struct {
+	PacketDevice
+	filter   *filter.Filter
+	bypassed *atomic.Int64
+}
+
+// newFilteredDevice wraps dev so Recv only returns packets matching f; passed-through
+// packets are counted in bypassed.
+func newFilteredDevice(dev PacketDevice, f *filter.Filter, bypassed *atomic.Int64) *filteredDevice {
+	return &filteredDevice{PacketDevice: dev, filter: f, bypassed: bypassed}
+}
+
+func (d *filteredDevice) Recv(buf []byte) (int, PacketAddress, error) {
+	for {
+		n, addr, err := d.PacketDevice.Recv(buf)
+		if err != nil {
+			return n, addr, err
+		}
+		if d.filter.Match(buf[:n], filterMeta(addr)) {
+			return n, addr, nil
+		}
+		if err := d.PacketDevice.Send(buf[:n], addr); err != nil {
+			return 0, PacketAddress{}, err
+		}
+		d.bypassed.Add(1)
+	}
+}
+
+func filterMeta(addr PacketAddress) filter.Meta {
+	return filter.Meta{
+		Outbound:  addr.Outbound,
+		Loopback:  addr.Loopback,
+		IfIdx:     addr.IfIdx,
+		SubIfIdx:  addr.SubIfIdx,
+		Timestamp: addr.Timestamp.UnixNano(),
+	}
+}
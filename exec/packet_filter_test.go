@@ -0,0 +1,198 @@
+package exec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chaosblade-win/internal/filter"
+	"chaosblade-win/internal/packet"
+)
+
+// testTCPPacket builds an outbound IPv4 TCP SYN to dstPort whose IP ID is id.
+func testTCPPacket(t testing.TB, id, dstPort uint16) []byte {
+	t.Helper()
+	b := make([]byte, 40)
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:], 40)
+	binary.BigEndian.PutUint16(b[4:], id)
+	b[8], b[9] = 64, byte(packet.ProtoTCP)
+	copy(b[12:16], []byte{10, 0, 0, 1})
+	copy(b[16:20], []byte{10, 0, 0, 2})
+	binary.BigEndian.PutUint16(b[20:], 50000)
+	binary.BigEndian.PutUint16(b[22:], dstPort)
+	b[32], b[33] = 5<<4, byte(packet.TCPSyn)
+	p, err := packet.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RecomputeChecksums()
+	return b
+}
+
+// mixedPackets returns n packets alternating between UDP to port 53 (even IDs) and TCP
+// to port 443 (odd IDs).
+func mixedPackets(t testing.TB, n int) [][]byte {
+	pkts := make([][]byte, n)
+	for i := range pkts {
+		if i%2 == 0 {
+			pkts[i] = testUDPPacket(t, uint16(i), 60)
+		} else {
+			pkts[i] = testTCPPacket(t, uint16(i), 443)
+		}
+	}
+	return pkts
+}
+
+func TestFilteredDeviceMemory(t *testing.T) {
+	tests := []struct {
+		filter      string
+		inbound     bool
+		wantMatched int
+		wantBypass  packet.Protocol // protocol of the packets that bypass, if any
+	}{
+		{"outbound and udp.DstPort == 53", false, 20, packet.ProtoTCP},
+		{"tcp.Syn and remotePort == 443", false, 20, packet.ProtoUDP},
+		{"inbound and udp", true, 20, packet.ProtoTCP},
+		{"outbound and udp", true, 0, 0},
+		{"event == PACKET and layer == NETWORK", false, 40, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			dev := NewMemoryPacketDevice()
+			for _, p := range mixedPackets(t, 40) {
+				dev.Inject(p, PacketAddress{Outbound: !tt.inbound})
+			}
+			dev.EndInput()
+
+			// Drop everything that matches so only bypassed packets come out.
+			r := NewNetworkDelayRunner(0, 0, 100, tt.filter, 0)
+			r.Device = dev
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := r.Run(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			s := r.Stats()
+			if s.Received != int64(tt.wantMatched) || s.Lost != s.Received || s.Bypassed != int64(40-tt.wantMatched) {
+				t.Errorf("stats = %+v, want %d matched", s, tt.wantMatched)
+			}
+			for _, p := range dev.Sent() {
+				d, err := packet.Decode(p.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.wantBypass != 0 && d.Protocol != tt.wantBypass {
+					t.Errorf("a %s packet bypassed the filter", d.Protocol)
+				}
+			}
+		})
+	}
+}
+
+func writeTestPcap(t *testing.T, path string, pkts [][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := writePcapHeader(f); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Unix(1700000000, 0)
+	for i, p := range pkts {
+		ts := base.Add(time.Duration(i) * time.Millisecond)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec, uint32(ts.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(ts.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(p)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(p)))
+		if _, err := f.Write(append(rec, p...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestPcap(t *testing.T, path string) [][]byte {
+	t.Helper()
+	dev, err := OpenPcapPacketDevice(path, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	var pkts [][]byte
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := dev.Recv(buf)
+		if errors.Is(err, io.EOF) {
+			return pkts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkts = append(pkts, append([]byte(nil), buf[:n]...))
+	}
+}
+
+func TestFilteredDevicePcap(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in.pcap"), filepath.Join(dir, "out.pcap")
+	writeTestPcap(t, in, mixedPackets(t, 30))
+
+	dev, err := OpenPcapPacketDevice(in, out, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewNetworkDelayRunner(5, 0, 0, "outbound and tcp.DstPort == 443", 0)
+	r.CorruptPercent = 100
+	r.CorruptMode = CorruptHeader
+	r.Seed = 3
+	r.Device = dev
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := r.Stats(); s.Received != 15 || s.Bypassed != 15 || s.Sent != 15 {
+		t.Errorf("stats = %+v, want 15 matched and 15 bypassed", s)
+	}
+	sent := readTestPcap(t, out)
+	if len(sent) != 30 {
+		t.Fatalf("capture holds %d packets, want 30", len(sent))
+	}
+	orig := mixedPackets(t, 30)
+	for _, p := range sent {
+		d, err := packet.Decode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := d.IPv4.ID
+		changed := string(p) != string(orig[id])
+		if d.TCP != nil && !changed {
+			t.Errorf("matched TCP packet %d was not corrupted", id)
+		}
+		if d.UDP != nil && changed {
+			t.Errorf("bypassed UDP packet %d was modified", id)
+		}
+	}
+}
+
+func TestNetworkRunnerRejectsBadFilterOffline(t *testing.T) {
+	r := NewNetworkDelayRunner(0, 0, 0, "tcp.DstPrt == 80", 0)
+	dev := NewMemoryPacketDevice()
+	dev.EndInput()
+	r.Device = dev
+	err := r.Run(context.Background())
+	var se *filter.SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("Run = %v, want a filter syntax error", err)
+	}
+}
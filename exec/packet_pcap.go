@@ -0,0 +1,256 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pcap link types understood when replaying. Captures are always written as raw IP.
+const (
+	pcapLinkNull     = 0
+	pcapLinkEthernet = 1
+	pcapLinkRaw      = 101
+	pcapLinkIPv4     = 228
+	pcapLinkIPv6     = 229
+)
+
+const (
+	pcapMagicMicros = 0xa1b2c3d4
+	pcapMagicNanos  = 0xa1b23c4d
+	pcapSnapLen     = 1 << 16
+)
+
+// PcapPacketDevice replays packets from a pcap file as if they had been intercepted
+// and writes whatever is sent to another pcap file, so shaping can be run offline
+// against recorded traffic and the result inspected with standard tools.
+type PcapPacketDevice struct {
+	in       *os.File
+	reader   *bufio.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+	outbound bool
+	pace     bool
+	first    time.Time // capture timestamp of the first packet
+	start    time.Time // wall clock when the first packet was replayed
+
+	outMu sync.Mutex
+	out   *os.File
+	w     *bufio.Writer
+
+	shutdown atomic.Bool
+	done     chan struct{}
+}
+
+// OpenPcapPacketDevice opens inPath for replay and, when outPath is non-empty, creates
+// it for capturing sent packets. Replayed packets are marked outbound unless inbound is
+// set. With pace, packets are released with their original inter-arrival gaps;
+// otherwise they are returned as fast as they are read.
+func OpenPcapPacketDevice(inPath, outPath string, inbound, pace bool) (*PcapPacketDevice, error) {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return nil, err
+	}
+	d := &PcapPacketDevice{in: in, reader: bufio.NewReader(in), outbound: !inbound, pace: pace, done: make(chan struct{})}
+	if err := d.readHeader(); err != nil {
+		in.Close()
+		return nil, fmt.Errorf("read pcap %s: %w", inPath, err)
+	}
+	if outPath != "" {
+		out, err := os.Create(outPath)
+		if err != nil {
+			in.Close()
+			return nil, err
+		}
+		d.out, d.w = out, bufio.NewWriter(out)
+		if err := writePcapHeader(d.w); err != nil {
+			d.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *PcapPacketDevice) readHeader() error {
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(d.reader, hdr); err != nil {
+		return err
+	}
+	switch {
+	case binary.LittleEndian.Uint32(hdr) == pcapMagicMicros:
+		d.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr) == pcapMagicMicros:
+		d.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr) == pcapMagicNanos:
+		d.order, d.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr) == pcapMagicNanos:
+		d.order, d.nanos = binary.BigEndian, true
+	default:
+		return errors.New("not a pcap file (pcapng is not supported)")
+	}
+	d.linkType = d.order.Uint32(hdr[20:]) & 0x0fffffff
+	switch d.linkType {
+	case pcapLinkNull, pcapLinkEthernet, pcapLinkRaw, pcapLinkIPv4, pcapLinkIPv6:
+		return nil
+	default:
+		return fmt.Errorf("unsupported pcap link type %d", d.linkType)
+	}
+}
+
+// Recv returns the next IP packet from the file, skipping frames that carry none.
+func (d *PcapPacketDevice) Recv(buf []byte) (int, PacketAddress, error) {
+	rec := make([]byte, 16)
+	for {
+		if d.shutdown.Load() {
+			return 0, PacketAddress{}, ErrPacketDeviceShutdown
+		}
+		if _, err := io.ReadFull(d.reader, rec); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return 0, PacketAddress{}, err
+		}
+		sec, frac := d.order.Uint32(rec), d.order.Uint32(rec[4:])
+		capLen := d.order.Uint32(rec[8:])
+		if capLen > pcapSnapLen*4 {
+			return 0, PacketAddress{}, fmt.Errorf("pcap record of %d bytes is too large", capLen)
+		}
+		frame := make([]byte, capLen)
+		if _, err := io.ReadFull(d.reader, frame); err != nil {
+			return 0, PacketAddress{}, io.EOF
+		}
+
+		pkt := d.ipPayload(frame)
+		if len(pkt) == 0 {
+			continue
+		}
+		if len(pkt) > len(buf) {
+			return 0, PacketAddress{}, io.ErrShortBuffer
+		}
+
+		ts := time.Unix(int64(sec), int64(frac)*1000)
+		if d.nanos {
+			ts = time.Unix(int64(sec), int64(frac))
+		}
+		if d.pace && !d.waitUntilDue(ts) {
+			return 0, PacketAddress{}, ErrPacketDeviceShutdown
+		}
+		addr := PacketAddress{Timestamp: time.Now(), Outbound: d.outbound, IPv6: pkt[0]>>4 == 6}
+		return copy(buf, pkt), addr, nil
+	}
+}
+
+// waitUntilDue sleeps until the replay clock reaches ts, relative to the first packet.
+// It reports false when the device is shut down while waiting.
+func (d *PcapPacketDevice) waitUntilDue(ts time.Time) bool {
+	if d.first.IsZero() {
+		d.first, d.start = ts, time.Now()
+		return true
+	}
+	wait := time.Until(d.start.Add(ts.Sub(d.first)))
+	if wait <= 0 {
+		return true
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-d.done:
+		return false
+	}
+}
+
+// ipPayload strips the link-layer header of frame, returning nil for non-IP frames.
+func (d *PcapPacketDevice) ipPayload(frame []byte) []byte {
+	switch d.linkType {
+	case pcapLinkNull:
+		if len(frame) < 4 {
+			return nil
+		}
+		frame = frame[4:]
+	case pcapLinkEthernet:
+		if len(frame) < 14 {
+			return nil
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		frame = frame[14:]
+		for etherType == 0x8100 || etherType == 0x88a8 { // VLAN tags
+			if len(frame) < 4 {
+				return nil
+			}
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return nil
+		}
+	}
+	if len(frame) == 0 {
+		return nil
+	}
+	if v := frame[0] >> 4; v != 4 && v != 6 {
+		return nil
+	}
+	return frame
+}
+
+// Send appends pkt to the capture file, if one was requested.
+func (d *PcapPacketDevice) Send(pkt []byte, addr PacketAddress) error {
+	d.outMu.Lock()
+	defer d.outMu.Unlock()
+	if d.w == nil {
+		return nil
+	}
+	now := time.Now()
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec, uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(pkt)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(pkt)))
+	if _, err := d.w.Write(rec); err != nil {
+		return err
+	}
+	_, err := d.w.Write(pkt)
+	return err
+}
+
+// Shutdown makes Recv return ErrPacketDeviceShutdown.
+func (d *PcapPacketDevice) Shutdown() error {
+	if d.shutdown.CompareAndSwap(false, true) {
+		close(d.done)
+	}
+	return nil
+}
+
+// Close flushes the capture file and closes both files.
+func (d *PcapPacketDevice) Close() error {
+	d.Shutdown()
+	var errs []error
+	d.outMu.Lock()
+	if d.w != nil {
+		errs = append(errs, d.w.Flush(), d.out.Close())
+		d.w = nil
+	}
+	d.outMu.Unlock()
+	errs = append(errs, d.in.Close())
+	return errors.Join(errs...)
+}
+
+func writePcapHeader(w io.Writer) error {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr, pcapMagicMicros)
+	binary.LittleEndian.PutUint16(hdr[4:], 2) // version 2.4
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], pcapLinkRaw)
+	_, err := w.Write(hdr)
+	return err
+}
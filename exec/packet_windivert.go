@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+)
+
+// winDivertAddressSize is sizeof(WINDIVERT_ADDRESS) in WinDivert 2.x.
+const winDivertAddressSize = 80
+
+// WINDIVERT_ADDRESS flag bits in the 32-bit word at offset 8 (after the timestamp).
+const (
+	winDivertFlagOutbound = 1 << 17
+	winDivertFlagLoopback = 1 << 18
+	winDivertFlagIPv6     = 1 << 20
+	// IP, TCP and UDP checksum-valid bits.
+	winDivertFlagChecksums = 1<<21 | 1<<22 | 1<<23
+)
+
+// winDivertDevice is the PacketDevice backed by the WinDivert driver.
+type winDivertDevice struct {
+	handle   winDivertHandle
+	shutdown atomic.Bool
+}
+
+// OpenWinDivertDevice opens a WinDivert handle at the network layer for filter.
+func OpenWinDivertDevice(filter string) (PacketDevice, error) {
+	if err := loadWinDivert(); err != nil {
+		return nil, err
+	}
+	h, err := winDivertOpen(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &winDivertDevice{handle: h}, nil
+}
+
+func (d *winDivertDevice) Recv(buf []byte) (int, PacketAddress, error) {
+	raw := make([]byte, winDivertAddressSize)
+	n, addrLen, err := winDivertRecv(d.handle, buf, raw)
+	if err != nil {
+		if d.shutdown.Load() {
+			return 0, PacketAddress{}, ErrPacketDeviceShutdown
+		}
+		return 0, PacketAddress{}, err
+	}
+	return n, decodeWinDivertAddress(raw[:addrLen]), nil
+}
+
+func (d *winDivertDevice) Send(pkt []byte, addr PacketAddress) error {
+	raw := addr.raw
+	if len(raw) == 0 {
+		raw = encodeWinDivertAddress(addr)
+	} else if addr.ChecksumsValid && len(raw) >= 12 {
+		raw = append([]byte(nil), raw...)
+		binary.LittleEndian.PutUint32(raw[8:], binary.LittleEndian.Uint32(raw[8:])|winDivertFlagChecksums)
+	}
+	return winDivertSend(d.handle, pkt, raw)
+}
+
+func (d *winDivertDevice) Shutdown() error {
+	d.shutdown.Store(true)
+	return winDivertShutdown(d.handle)
+}
+
+func (d *winDivertDevice) Close() error {
+	winDivertClose(d.handle)
+	return nil
+}
+
+func decodeWinDivertAddress(raw []byte) PacketAddress {
+	addr := PacketAddress{Timestamp: time.Now(), raw: raw}
+	if len(raw) < 24 {
+		return addr
+	}
+	flags := binary.LittleEndian.Uint32(raw[8:])
+	addr.Outbound = flags&winDivertFlagOutbound != 0
+	addr.Loopback = flags&winDivertFlagLoopback != 0
+	addr.IPv6 = flags&winDivertFlagIPv6 != 0
+	addr.IfIdx = binary.LittleEndian.Uint32(raw[16:])
+	addr.SubIfIdx = binary.LittleEndian.Uint32(raw[20:])
+	return addr
+}
+
+// encodeWinDivertAddress builds a network-layer address for packets that did not come
+// from WinDivert, such as pcap replays.
+func encodeWinDivertAddress(addr PacketAddress) []byte {
+	raw := make([]byte, winDivertAddressSize)
+	var flags uint32 // layer and event are zero: WINDIVERT_LAYER_NETWORK, WINDIVERT_EVENT_NETWORK_PACKET
+	if addr.Outbound {
+		flags |= winDivertFlagOutbound
+	}
+	if addr.Loopback {
+		flags |= winDivertFlagLoopback
+	}
+	if addr.IPv6 {
+		flags |= winDivertFlagIPv6
+	}
+	if addr.ChecksumsValid {
+		flags |= winDivertFlagChecksums
+	}
+	binary.LittleEndian.PutUint32(raw[8:], flags)
+	binary.LittleEndian.PutUint32(raw[16:], addr.IfIdx)
+	binary.LittleEndian.PutUint32(raw[20:], addr.SubIfIdx)
+	return raw
+}
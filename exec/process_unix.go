@@ -0,0 +1,17 @@
+//go:build unix
+
+package exec
+
+import (
+	"errors"
+	"syscall"
+)
+
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// Signal 0 performs the existence and permission checks without delivering anything.
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
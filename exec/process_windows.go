@@ -0,0 +1,27 @@
+package exec
+
+import "syscall"
+
+func isProcessAlive(pid int) bool {
+	const (
+		processQueryLimitedInformation = 0x1000 // matches Windows PROCESS_QUERY_LIMITED_INFORMATION
+		stillActive                    = 259
+	)
+
+	if pid <= 0 {
+		return false
+	}
+
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+
+	return code == stillActive
+}
@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket paces a byte stream to a sustained rate while allowing bursts of up to
+// burst bytes. It starts full.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int64) *tokenBucket {
+	b := float64(max(burst, 1))
+	return &tokenBucket{rate: float64(max(rate, 1)), burst: b, tokens: b, last: time.Now()}
+}
+
+// refill adds the tokens accrued since the last call. Callers hold mu.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes n tokens, going into debt if necessary, and returns how long the
+// caller must wait before the debt is repaid.
+func (b *tokenBucket) reserve(now time.Time, n int64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until n tokens are available or ctx ends.
+func (b *tokenBucket) wait(ctx context.Context, n int64) error {
+	d := b.reserve(time.Now(), n)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
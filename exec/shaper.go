@@ -0,0 +1,157 @@
+package exec
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// QueuePolicy selects how a bandwidth shaper drops packets when its queue fills.
+type QueuePolicy string
+
+const (
+	// QueueTailDrop drops arriving packets only when the queue is full.
+	QueueTailDrop QueuePolicy = "tail-drop"
+	// QueueRED drops arriving packets early with a probability that rises with the
+	// average queue depth (Random Early Detection), then tail-drops at the limit.
+	QueueRED QueuePolicy = "red"
+)
+
+// ParseQueuePolicy validates a queue policy name.
+func ParseQueuePolicy(s string) (QueuePolicy, error) {
+	switch p := QueuePolicy(s); p {
+	case QueueTailDrop, QueueRED:
+		return p, nil
+	case "":
+		return QueueTailDrop, nil
+	default:
+		return "", fmt.Errorf("unknown queue policy %q (expected tail-drop or red)", s)
+	}
+}
+
+const (
+	// redWeight is the EWMA weight for the average queue depth, as in classic RED.
+	redWeight = 0.002
+	// redMaxP is the early-drop probability when the average reaches the limit.
+	redMaxP = 0.1
+	// minShaperBurst keeps the bucket able to pass a full-size packet without debt.
+	minShaperBurst = 1600
+)
+
+// shaperEntry is a packet in the shaper backlog with its departure time.
+type shaperEntry struct {
+	departure time.Time
+	size      int
+}
+
+// shaper models a bottleneck link: a token bucket drains a FIFO queue at a fixed rate
+// with bursts of up to burst bytes. Because the queue is FIFO, each admitted packet's
+// departure time is known on arrival, so the shaper only computes when a packet leaves
+// the link and the delay queue holds it until then.
+type shaper struct {
+	mu           sync.Mutex
+	bucket       *tokenBucket
+	limitPackets int   // 0 = no packet limit
+	limitBytes   int64 // 0 = no byte limit
+	policy       QueuePolicy
+	rng          *rand.Rand
+
+	backlog      []shaperEntry
+	backlogBytes int64
+	avg          float64 // RED average depth, in the unit of the limit
+	dropped      int64
+}
+
+// ShaperStats reports a shaper's current queue depth and cumulative drops.
+type ShaperStats struct {
+	QueuedPackets int
+	QueuedBytes   int64
+	Dropped       int64
+}
+
+// newShaper builds a shaper for rate bytes/s. A burst below one full-size packet is
+// raised to it. At least one of limitPackets and limitBytes should be set.
+func newShaper(rate, burst int64, limitPackets int, limitBytes int64, policy QueuePolicy, rng *rand.Rand) *shaper {
+	if burst <= 0 {
+		burst = rate / 100 // 10ms worth of traffic
+	}
+	return &shaper{
+		bucket:       newTokenBucket(rate, max(burst, minShaperBurst)),
+		limitPackets: max(limitPackets, 0),
+		limitBytes:   max(limitBytes, 0),
+		policy:       policy,
+		rng:          rng,
+	}
+}
+
+// admit queues a packet of size bytes arriving at now and returns when it leaves the
+// link, or false when the queue policy drops it.
+func (s *shaper) admit(now time.Time, size int) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+
+	if s.full(size) || s.earlyDrop() {
+		s.dropped++
+		return time.Time{}, false
+	}
+
+	departure := now.Add(s.bucket.reserve(now, int64(size)))
+	s.backlog = append(s.backlog, shaperEntry{departure: departure, size: size})
+	s.backlogBytes += int64(size)
+	return departure, true
+}
+
+// expire removes packets that have left the link by now.
+func (s *shaper) expire(now time.Time) {
+	i := 0
+	for i < len(s.backlog) && !s.backlog[i].departure.After(now) {
+		s.backlogBytes -= int64(s.backlog[i].size)
+		i++
+	}
+	if i > 0 {
+		s.backlog = append(s.backlog[:0], s.backlog[i:]...)
+	}
+}
+
+func (s *shaper) full(size int) bool {
+	if s.limitPackets > 0 && len(s.backlog)+1 > s.limitPackets {
+		return true
+	}
+	return s.limitBytes > 0 && s.backlogBytes+int64(size) > s.limitBytes
+}
+
+// earlyDrop applies RED: no drops below a third of the limit, then a probability
+// rising linearly to redMaxP as the average depth approaches the limit.
+func (s *shaper) earlyDrop() bool {
+	if s.policy != QueueRED {
+		return false
+	}
+	depth, limit := float64(len(s.backlog)), float64(s.limitPackets)
+	if s.limitPackets == 0 {
+		depth, limit = float64(s.backlogBytes), float64(s.limitBytes)
+	}
+	if limit <= 0 {
+		return false
+	}
+	s.avg = (1-redWeight)*s.avg + redWeight*depth
+
+	minTh := limit / 3
+	if s.avg < minTh {
+		return false
+	}
+	if s.avg >= limit {
+		return true
+	}
+	p := redMaxP * (s.avg - minTh) / (limit - minTh)
+	return s.rng.Float64() < p
+}
+
+// stats reports the queue as of now.
+func (s *shaper) stats(now time.Time) ShaperStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	return ShaperStats{QueuedPackets: len(s.backlog), QueuedBytes: s.backlogBytes, Dropped: s.dropped}
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,8 +20,19 @@ type ExperimentState struct {
 	PID       int               `json:"pid"`
 	StartedAt time.Time         `json:"startedAt"`
 	Params    map[string]string `json:"params,omitempty"`
+	Status    map[string]string `json:"status,omitempty"`
+	// Artifacts lists paths the experiment created that destroy removes, so cleanup
+	// happens even when the owning process is killed before it can tidy up.
+	Artifacts []string `json:"artifacts,omitempty"`
+	// Mounts lists interposed filesystems destroy unmounts before removing artifacts.
+	Mounts []string `json:"mounts,omitempty"`
+	// Backups lists original copies of tampered files that destroy restores.
+	Backups []FileBackup `json:"backups,omitempty"`
 }
 
+// stateMu serializes read-modify-write updates of state files within one process.
+var stateMu sync.Mutex
+
 // ErrExperimentRunning indicates an experiment of the same target is already tracked.
 var ErrExperimentRunning = errors.New("experiment already running; destroy it first")
 
@@ -54,11 +65,117 @@ func TrackExperiment(target, action string, params map[string]string) (string, f
 	}
 
 	cleanup := func() {
+		// Hold stateMu so a status update racing with cleanup either lands before the
+		// file is removed or finds it gone; it can never recreate it.
+		stateMu.Lock()
+		defer stateMu.Unlock()
 		_ = clearStateByID(target, id, pid)
 	}
 	return id, cleanup, nil
 }
 
+// UpdateExperimentStatus replaces the runtime status of an experiment owned by the
+// current process. It returns an error, and writes nothing, when the record is missing
+// (for example after cleanup) or owned elsewhere.
+func UpdateExperimentStatus(target, id string, status map[string]string) error {
+	return updateOwnedState(target, id, func(state *ExperimentState) error {
+		state.Status = status
+		return nil
+	})
+}
+
+// artifactTracker lets a runner record the files it creates as artifacts of the
+// experiment that owns it, so destroy removes them even when it kills the runner before
+// its deferred cleanup can run.
+type artifactTracker struct {
+	target, id string
+}
+
+// TrackArtifacts makes the runner record files it creates against the given experiment.
+func (t *artifactTracker) TrackArtifacts(target, id string) {
+	t.target, t.id = target, id
+}
+
+// recordArtifact registers path when tracking is enabled.
+func (t *artifactTracker) recordArtifact(path string) error {
+	if t.id == "" {
+		return nil
+	}
+	return AddExperimentArtifact(t.target, t.id, path)
+}
+
+// AddExperimentArtifact records a path that destroy must remove for an experiment owned
+// by the current process.
+func AddExperimentArtifact(target, id, path string) error {
+	return updateOwnedState(target, id, func(state *ExperimentState) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		state.Artifacts = append(state.Artifacts, abs)
+		return nil
+	})
+}
+
+// AddExperimentMount records an interposed mount point that destroy must unmount for an
+// experiment owned by the current process.
+func AddExperimentMount(target, id, path string) error {
+	return updateOwnedState(target, id, func(state *ExperimentState) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		state.Mounts = append(state.Mounts, abs)
+		return nil
+	})
+}
+
+// updateOwnedState applies update to the state of an experiment owned by the current
+// process and writes it back.
+func updateOwnedState(target, id string, update func(*ExperimentState) error) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	state, _, err := loadStateByID(target, id)
+	if err != nil {
+		return err
+	}
+	if state.ID == "" {
+		return fmt.Errorf("no tracked %s experiment with id %s", target, id)
+	}
+	if state.PID != os.Getpid() {
+		return fmt.Errorf("%s experiment %s is owned by pid %d", target, id, state.PID)
+	}
+	if err := update(&state); err != nil {
+		return err
+	}
+	return writeStateFileForID(target, id, state)
+}
+
+// revertExperiment undoes the side effects recorded for a destroyed experiment.
+func revertExperiment(state ExperimentState) {
+	for _, path := range state.Mounts {
+		if err := unmountInterposed(path); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: unmount %s: %v\n", path, err)
+		}
+	}
+	restoreBackups(state)
+	removeArtifacts(state)
+}
+
+// removeArtifacts deletes the paths recorded for a destroyed experiment. A killed
+// process may briefly keep handles open, so removal is retried for a few seconds.
+func removeArtifacts(state ExperimentState) {
+	for _, path := range state.Artifacts {
+		for attempt := 0; attempt < 20; attempt++ {
+			if err := os.RemoveAll(path); err == nil {
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+}
+
 // KillTrackedExperiment terminates the process recorded for a target and clears state.
 // KillTrackedExperiment terminates the process recorded for a target and clears state.
 // If id is empty, attempts to stop all experiments for the target.
@@ -72,6 +189,7 @@ func KillTrackedExperiment(target, id string) (*ExperimentState, error) {
 			return nil, fmt.Errorf("no tracked %s experiment with id %s", target, id)
 		}
 		if !alive {
+			revertExperiment(state)
 			_ = clearStateByID(target, id, 0)
 			return nil, fmt.Errorf("no active %s experiment (stale record removed)", target)
 		}
@@ -82,6 +200,7 @@ func KillTrackedExperiment(target, id string) (*ExperimentState, error) {
 		if err := proc.Kill(); err != nil {
 			return nil, fmt.Errorf("terminate process %d: %w", state.PID, err)
 		}
+		revertExperiment(state)
 		_ = clearStateByID(target, id, state.PID)
 		return &state, nil
 	}
@@ -94,11 +213,13 @@ func KillTrackedExperiment(target, id string) (*ExperimentState, error) {
 	var last *ExperimentState
 	for _, s := range states {
 		if s.PID == 0 {
+			revertExperiment(s)
 			_ = clearStateByID(target, s.ID, 0)
 			continue
 		}
 		alive := isProcessAlive(s.PID)
 		if !alive {
+			revertExperiment(s)
 			_ = clearStateByID(target, s.ID, 0)
 			continue
 		}
@@ -106,6 +227,7 @@ func KillTrackedExperiment(target, id string) (*ExperimentState, error) {
 		if err == nil {
 			_ = proc.Kill()
 		}
+		revertExperiment(s)
 		_ = clearStateByID(target, s.ID, s.PID)
 		last = &s
 	}
@@ -181,30 +303,6 @@ func stateFile(target string) string {
 	return filepath.Join(os.TempDir(), "chaosblade-win", fmt.Sprintf("%s.json", target))
 }
 
-func isProcessAlive(pid int) bool {
-	const (
-		processQueryLimitedInformation = 0x1000 // matches Windows PROCESS_QUERY_LIMITED_INFORMATION
-		stillActive                    = 259
-	)
-
-	if pid <= 0 {
-		return false
-	}
-
-	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
-	if err != nil {
-		return false
-	}
-	defer syscall.CloseHandle(handle)
-
-	var code uint32
-	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
-		return false
-	}
-
-	return code == stillActive
-}
-
 func stateDirForTarget(target string) string {
 	return filepath.Join(os.TempDir(), "chaosblade-win", target)
 }
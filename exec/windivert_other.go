@@ -0,0 +1,30 @@
+//go:build !windows
+
+package exec
+
+// WinDivert is Windows-only; on other platforms the binding reports it as missing so
+// the shaping logic still compiles for packet devices that do not need the driver.
+
+type winDivertHandle = uintptr
+
+func loadWinDivert() error {
+	return ErrWinDivertMissing
+}
+
+func winDivertOpen(filter string) (winDivertHandle, error) {
+	return 0, ErrWinDivertMissing
+}
+
+func winDivertRecv(h winDivertHandle, pktBuf []byte, addrBuf []byte) (int, int, error) {
+	return 0, 0, ErrWinDivertMissing
+}
+
+func winDivertSend(h winDivertHandle, pkt []byte, addr []byte) error {
+	return ErrWinDivertMissing
+}
+
+func winDivertShutdown(h winDivertHandle) error {
+	return ErrWinDivertMissing
+}
+
+func winDivertClose(h winDivertHandle) {}
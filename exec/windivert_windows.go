@@ -0,0 +1,133 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// --- Minimal WinDivert binding ---
+
+const (
+	winDivertLayerNetwork = 0
+	// Only receiving is shut down so packets still queued for delay can be reinjected.
+	winDivertShutdownRecv = 1
+)
+
+type winDivertHandle = syscall.Handle
+
+var (
+	winDivertDLL          = syscall.NewLazyDLL("WinDivert.dll")
+	procWinDivertOpen     = winDivertDLL.NewProc("WinDivertOpen")
+	procWinDivertRecv     = winDivertDLL.NewProc("WinDivertRecv")
+	procWinDivertSend     = winDivertDLL.NewProc("WinDivertSend")
+	procWinDivertClose    = winDivertDLL.NewProc("WinDivertClose")
+	procWinDivertShutdown = winDivertDLL.NewProc("WinDivertShutdown")
+)
+
+func loadWinDivert() error {
+	if err := winDivertDLL.Load(); err != nil {
+		if errors.Is(err, syscall.ERROR_MOD_NOT_FOUND) || errors.Is(err, syscall.Errno(193)) {
+			// missing DLL or wrong arch
+			return ErrWinDivertMissing
+		}
+		return err
+	}
+	return nil
+}
+
+func winDivertOpen(filter string) (winDivertHandle, error) {
+	filterPtr, err := syscall.BytePtrFromString(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	h, _, callErr := procWinDivertOpen.Call(
+		uintptr(unsafe.Pointer(filterPtr)),
+		uintptr(winDivertLayerNetwork),
+		uintptr(int16(0)),
+		uintptr(uint64(0)),
+	)
+	// WinDivertOpen returns NULL or INVALID_HANDLE_VALUE (-1) on failure
+	if h == 0 || h == ^uintptr(0) {
+		if errors.Is(callErr, syscall.ERROR_FILE_NOT_FOUND) {
+			// driver (.sys) missing next to the DLL
+			return 0, ErrWinDivertMissing
+		}
+		// Return a more detailed error including the underlying syscall error
+		if callErr != nil {
+			if errno, ok := callErr.(syscall.Errno); ok {
+				return 0, fmt.Errorf("WinDivertOpen failed: %w (errno=%d)", errno, int(errno))
+			}
+			return 0, fmt.Errorf("WinDivertOpen failed: %v", callErr)
+		}
+		return 0, fmt.Errorf("WinDivertOpen failed: unknown error (handle invalid)")
+	}
+	return winDivertHandle(h), nil
+}
+
+func winDivertRecv(h winDivertHandle, pktBuf []byte, addrBuf []byte) (int, int, error) {
+	var recvLen uint64
+	addrLen := uint32(len(addrBuf))
+
+	r1, _, err := procWinDivertRecv.Call(
+		uintptr(h),
+		uintptr(unsafe.Pointer(&pktBuf[0])),
+		uintptr(len(pktBuf)),
+		uintptr(unsafe.Pointer(&recvLen)),
+		uintptr(unsafe.Pointer(&addrBuf[0])),
+		uintptr(unsafe.Pointer(&addrLen)),
+		uintptr(uint64(0)),
+	)
+	if r1 == 0 {
+		if err != nil {
+			if errno, ok := err.(syscall.Errno); ok {
+				return 0, 0, fmt.Errorf("WinDivertRecv failed: %w (errno=%d)", errno, int(errno))
+			}
+			return 0, 0, fmt.Errorf("WinDivertRecv failed: %v", err)
+		}
+		return 0, 0, fmt.Errorf("WinDivertRecv failed: unknown error (r1==0)")
+	}
+	return int(recvLen), int(addrLen), nil
+}
+
+func winDivertSend(h winDivertHandle, pkt []byte, addr []byte) error {
+	var sendLen uint64
+	addrLen := uint32(len(addr))
+
+	r1, _, err := procWinDivertSend.Call(
+		uintptr(h),
+		uintptr(unsafe.Pointer(&pkt[0])),
+		uintptr(len(pkt)),
+		uintptr(unsafe.Pointer(&sendLen)),
+		uintptr(unsafe.Pointer(&addr[0])),
+		uintptr(unsafe.Pointer(&addrLen)),
+		uintptr(uint64(0)),
+	)
+	if r1 == 0 {
+		if err != nil {
+			if errno, ok := err.(syscall.Errno); ok {
+				return fmt.Errorf("WinDivertSend failed: %w (errno=%d)", errno, int(errno))
+			}
+			return fmt.Errorf("WinDivertSend failed: %v", err)
+		}
+		return fmt.Errorf("WinDivertSend failed: unknown error (r1==0)")
+	}
+	if int(sendLen) != len(pkt) {
+		return fmt.Errorf("partial send: %d/%d", sendLen, len(pkt))
+	}
+	return nil
+}
+
+func winDivertShutdown(h winDivertHandle) error {
+	r1, _, err := procWinDivertShutdown.Call(uintptr(h), uintptr(winDivertShutdownRecv))
+	if r1 == 0 && err != nil {
+		return err
+	}
+	return nil
+}
+
+func winDivertClose(h winDivertHandle) {
+	procWinDivertClose.Call(uintptr(h))
+}
@@ -0,0 +1,378 @@
+package filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"chaosblade-win/internal/packet"
+)
+
+type fieldKind int
+
+const (
+	kindNumber  fieldKind = iota
+	kindAddr4             // IPv4 address
+	kindAddr6             // IPv6 address
+	kindAddrAny           // either family; IPv4 is compared as ::ffff:a.b.c.d
+)
+
+// field describes one filter field: its value width and how to read it from a packet.
+// get reports false when the packet does not have the field.
+type field struct {
+	name    string
+	kind    fieldKind
+	bits    int
+	indexed bool
+	symbols map[string]uint64 // named values, such as PACKET for event
+	get     func(e *env, idx int) (u128, bool)
+}
+
+// parseValue converts a literal for comparison with f.
+func (f *field) parseValue(lit string) (u128, error) {
+	if v, ok := f.symbols[strings.ToUpper(lit)]; ok {
+		return num(v), nil
+	}
+	v, addr, err := parseLiteral(lit)
+	if err != nil {
+		return v, err
+	}
+	switch f.kind {
+	case kindAddr4:
+		if addr.IsValid() && !addr.Is4() {
+			return v, fmt.Errorf("%s needs an IPv4 address, not %s", f.name, lit)
+		}
+	case kindAddr6:
+		if addr.Is4() {
+			return v, fmt.Errorf("%s needs an IPv6 address, not %s", f.name, lit)
+		}
+	case kindAddrAny:
+		if addr.Is4() {
+			v = addrValue(netip.AddrFrom16(addr.As16()))
+		}
+	default:
+		if addr.IsValid() {
+			return v, fmt.Errorf("%s is not an address field", f.name)
+		}
+	}
+	if !v.fits(f.bits) {
+		return v, fmt.Errorf("value %s is too large for %s (%d bits)", lit, f.name, f.bits)
+	}
+	return v, nil
+}
+
+var fields = map[string]*field{}
+
+// otherLayerFields are WinDivert fields that only exist at the flow, socket or reflect
+// layers, which this package does not model.
+var otherLayerFields = map[string]bool{
+	"endpointid": true, "parentendpointid": true, "processid": true, "priority": true,
+}
+
+// Values of the event and layer fields. At the network layer every packet is event
+// PACKET on layer NETWORK; the other names are accepted and simply never match.
+var (
+	eventSymbols = map[string]uint64{
+		"PACKET": 0, "ESTABLISHED": 1, "DELETED": 2, "BIND": 3, "CONNECT": 4,
+		"LISTEN": 5, "ACCEPT": 6, "CLOSE": 7, "OPEN": 8,
+	}
+	layerSymbols = map[string]uint64{
+		"NETWORK": 0, "NETWORK_FORWARD": 1, "FLOW": 2, "SOCKET": 3, "REFLECT": 4,
+	}
+)
+
+func lookupField(name string) *field {
+	return fields[strings.ToLower(name)]
+}
+
+func def(name string, kind fieldKind, bits int, get func(e *env) (u128, bool)) {
+	fields[strings.ToLower(name)] = &field{name: name, kind: kind, bits: bits, get: func(e *env, _ int) (u128, bool) { return get(e) }}
+}
+
+func defIndexed(name string, width int, bytes func(e *env) ([]byte, bool)) {
+	fields[strings.ToLower(name)] = &field{name: name, bits: width * 8, indexed: true, get: func(e *env, idx int) (u128, bool) {
+		b, ok := bytes(e)
+		if !ok {
+			return u128{}, false
+		}
+		// The index counts units of the field's width; negative indexes count from the end.
+		off := idx * width
+		if idx < 0 {
+			off = len(b) + idx*width
+		}
+		if off < 0 || off+width > len(b) {
+			return u128{}, false
+		}
+		switch width {
+		case 1:
+			return num(uint64(b[off])), true
+		case 2:
+			return num(uint64(binary.BigEndian.Uint16(b[off:]))), true
+		default:
+			return num(uint64(binary.BigEndian.Uint32(b[off:]))), true
+		}
+	}}
+}
+
+// Accessors for the layers a field belongs to; each reports whether it is present.
+func (e *env) ipv4() (*packet.IPv4, bool) {
+	if e.pkt == nil || e.pkt.IPv4 == nil {
+		return nil, false
+	}
+	return e.pkt.IPv4, true
+}
+
+func (e *env) ipv6() (*packet.IPv6, bool) {
+	if e.pkt == nil || e.pkt.IPv6 == nil {
+		return nil, false
+	}
+	return e.pkt.IPv6, true
+}
+
+func (e *env) tcp() (*packet.TCP, bool) {
+	if e.pkt == nil || e.pkt.TCP == nil {
+		return nil, false
+	}
+	return e.pkt.TCP, true
+}
+
+func (e *env) udp() (*packet.UDP, bool) {
+	if e.pkt == nil || e.pkt.UDP == nil {
+		return nil, false
+	}
+	return e.pkt.UDP, true
+}
+
+func (e *env) icmp(proto packet.Protocol) (*packet.ICMP, bool) {
+	if e.pkt == nil || e.pkt.ICMP == nil || e.pkt.Protocol != proto {
+		return nil, false
+	}
+	return e.pkt.ICMP, true
+}
+
+// endpoints returns the local and remote address and port, which depend on direction.
+func (e *env) endpoints() (local, remote netip.Addr, lport, rport uint16, ports bool) {
+	t := e.pkt.FiveTuple()
+	ports = e.pkt.TCP != nil || e.pkt.UDP != nil
+	if e.meta.Outbound {
+		return t.Src, t.Dst, t.SrcPort, t.DstPort, ports
+	}
+	return t.Dst, t.Src, t.DstPort, t.SrcPort, ports
+}
+
+func mapped(a netip.Addr) u128 {
+	return addrValue(netip.AddrFrom16(a.As16()))
+}
+
+func init() {
+	always := func(v func(e *env) uint64) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) { return num(v(e)), true }
+	}
+	flag := func(v func(e *env) bool) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) { return boolVal(v(e)), true }
+	}
+	decoded := func(v func(p *packet.Packet) uint64) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) {
+			if e.pkt == nil {
+				return u128{}, false
+			}
+			return num(v(e.pkt)), true
+		}
+	}
+
+	// Metadata.
+	def("zero", kindNumber, 1, always(func(*env) uint64 { return 0 }))
+	def("timestamp", kindNumber, 64, always(func(e *env) uint64 { return uint64(e.meta.Timestamp) }))
+	def("event", kindNumber, 8, always(func(*env) uint64 { return eventSymbols["PACKET"] }))
+	fields["event"].symbols = eventSymbols
+	def("layer", kindNumber, 8, always(func(*env) uint64 { return layerSymbols["NETWORK"] }))
+	fields["layer"].symbols = layerSymbols
+	def("random8", kindNumber, 8, always(func(e *env) uint64 { return uint64(e.random() & 0xff) }))
+	def("random16", kindNumber, 16, always(func(e *env) uint64 { return uint64(e.random() & 0xffff) }))
+	def("random32", kindNumber, 32, always(func(e *env) uint64 { return uint64(e.random()) }))
+	def("outbound", kindNumber, 1, flag(func(e *env) bool { return e.meta.Outbound }))
+	def("inbound", kindNumber, 1, flag(func(e *env) bool { return !e.meta.Outbound }))
+	def("loopback", kindNumber, 1, flag(func(e *env) bool { return e.meta.Loopback }))
+	def("impostor", kindNumber, 1, flag(func(e *env) bool { return e.meta.Impostor }))
+	def("ifIdx", kindNumber, 32, always(func(e *env) uint64 { return uint64(e.meta.IfIdx) }))
+	def("subIfIdx", kindNumber, 32, always(func(e *env) uint64 { return uint64(e.meta.SubIfIdx) }))
+	def("length", kindNumber, 32, always(func(e *env) uint64 { return uint64(len(e.raw)) }))
+	def("fragment", kindNumber, 1, decoded(func(p *packet.Packet) uint64 { return boolU(p.IsFragment()) }))
+	def("protocol", kindNumber, 8, decoded(func(p *packet.Packet) uint64 { return uint64(p.Protocol) }))
+	defIndexed("packet", 1, func(e *env) ([]byte, bool) { return e.raw, true })
+	defIndexed("packet16", 2, func(e *env) ([]byte, bool) { return e.raw, true })
+	defIndexed("packet32", 4, func(e *env) ([]byte, bool) { return e.raw, true })
+
+	// Layer presence.
+	def("ip", kindNumber, 1, flag(func(e *env) bool { _, ok := e.ipv4(); return ok }))
+	def("ipv6", kindNumber, 1, flag(func(e *env) bool { _, ok := e.ipv6(); return ok }))
+	def("tcp", kindNumber, 1, flag(func(e *env) bool { _, ok := e.tcp(); return ok }))
+	def("udp", kindNumber, 1, flag(func(e *env) bool { _, ok := e.udp(); return ok }))
+	def("icmp", kindNumber, 1, flag(func(e *env) bool { _, ok := e.icmp(packet.ProtoICMP); return ok }))
+	def("icmpv6", kindNumber, 1, flag(func(e *env) bool { _, ok := e.icmp(packet.ProtoICMPv6); return ok }))
+
+	// Direction-relative endpoints.
+	def("localAddr", kindAddrAny, 128, func(e *env) (u128, bool) {
+		if e.pkt == nil {
+			return u128{}, false
+		}
+		l, _, _, _, _ := e.endpoints()
+		return mapped(l), true
+	})
+	def("remoteAddr", kindAddrAny, 128, func(e *env) (u128, bool) {
+		if e.pkt == nil {
+			return u128{}, false
+		}
+		_, r, _, _, _ := e.endpoints()
+		return mapped(r), true
+	})
+	def("localPort", kindNumber, 16, func(e *env) (u128, bool) {
+		if e.pkt == nil {
+			return u128{}, false
+		}
+		_, _, l, _, ok := e.endpoints()
+		return num(uint64(l)), ok
+	})
+	def("remotePort", kindNumber, 16, func(e *env) (u128, bool) {
+		if e.pkt == nil {
+			return u128{}, false
+		}
+		_, _, _, r, ok := e.endpoints()
+		return num(uint64(r)), ok
+	})
+
+	ip4 := func(v func(h *packet.IPv4) uint64) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) {
+			h, ok := e.ipv4()
+			if !ok {
+				return u128{}, false
+			}
+			return num(v(h)), true
+		}
+	}
+	def("ip.HdrLength", kindNumber, 4, ip4(func(h *packet.IPv4) uint64 { return uint64(h.IHL) }))
+	def("ip.TOS", kindNumber, 8, ip4(func(h *packet.IPv4) uint64 { return uint64(h.TOS) }))
+	def("ip.Length", kindNumber, 16, ip4(func(h *packet.IPv4) uint64 { return uint64(h.Length) }))
+	def("ip.Id", kindNumber, 16, ip4(func(h *packet.IPv4) uint64 { return uint64(h.ID) }))
+	def("ip.DF", kindNumber, 1, ip4(func(h *packet.IPv4) uint64 { return boolU(h.Flags&packet.IPv4DontFragment != 0) }))
+	def("ip.MF", kindNumber, 1, ip4(func(h *packet.IPv4) uint64 { return boolU(h.Flags&packet.IPv4MoreFragments != 0) }))
+	def("ip.FragOff", kindNumber, 13, ip4(func(h *packet.IPv4) uint64 { return uint64(h.FragOffset) }))
+	def("ip.TTL", kindNumber, 8, ip4(func(h *packet.IPv4) uint64 { return uint64(h.TTL) }))
+	def("ip.Protocol", kindNumber, 8, ip4(func(h *packet.IPv4) uint64 { return uint64(h.Protocol) }))
+	def("ip.Checksum", kindNumber, 16, ip4(func(h *packet.IPv4) uint64 { return uint64(h.Checksum) }))
+	def("ip.SrcAddr", kindAddr4, 32, ip4(func(h *packet.IPv4) uint64 { return addrValue(h.Src).lo }))
+	def("ip.DstAddr", kindAddr4, 32, ip4(func(h *packet.IPv4) uint64 { return addrValue(h.Dst).lo }))
+
+	ip6 := func(v func(h *packet.IPv6) uint64) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) {
+			h, ok := e.ipv6()
+			if !ok {
+				return u128{}, false
+			}
+			return num(v(h)), true
+		}
+	}
+	def("ipv6.TrafficClass", kindNumber, 8, ip6(func(h *packet.IPv6) uint64 { return uint64(h.TrafficClass) }))
+	def("ipv6.FlowLabel", kindNumber, 20, ip6(func(h *packet.IPv6) uint64 { return uint64(h.FlowLabel) }))
+	def("ipv6.Length", kindNumber, 16, ip6(func(h *packet.IPv6) uint64 { return uint64(h.PayloadLength) }))
+	def("ipv6.NextHdr", kindNumber, 8, ip6(func(h *packet.IPv6) uint64 { return uint64(h.NextHeader) }))
+	def("ipv6.HopLimit", kindNumber, 8, ip6(func(h *packet.IPv6) uint64 { return uint64(h.HopLimit) }))
+	def("ipv6.SrcAddr", kindAddr6, 128, func(e *env) (u128, bool) {
+		h, ok := e.ipv6()
+		if !ok {
+			return u128{}, false
+		}
+		return addrValue(h.Src), true
+	})
+	def("ipv6.DstAddr", kindAddr6, 128, func(e *env) (u128, bool) {
+		h, ok := e.ipv6()
+		if !ok {
+			return u128{}, false
+		}
+		return addrValue(h.Dst), true
+	})
+
+	for _, proto := range []packet.Protocol{packet.ProtoICMP, packet.ProtoICMPv6} {
+		proto := proto
+		ic := func(v func(h *packet.ICMP) uint64) func(e *env) (u128, bool) {
+			return func(e *env) (u128, bool) {
+				h, ok := e.icmp(proto)
+				if !ok {
+					return u128{}, false
+				}
+				return num(v(h)), true
+			}
+		}
+		prefix := proto.String() + "."
+		def(prefix+"Type", kindNumber, 8, ic(func(h *packet.ICMP) uint64 { return uint64(h.Type) }))
+		def(prefix+"Code", kindNumber, 8, ic(func(h *packet.ICMP) uint64 { return uint64(h.Code) }))
+		def(prefix+"Checksum", kindNumber, 16, ic(func(h *packet.ICMP) uint64 { return uint64(h.Checksum) }))
+		def(prefix+"Body", kindNumber, 32, ic(func(h *packet.ICMP) uint64 { return uint64(binary.BigEndian.Uint32(h.Rest[:])) }))
+	}
+
+	tcp := func(v func(h *packet.TCP) uint64) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) {
+			h, ok := e.tcp()
+			if !ok {
+				return u128{}, false
+			}
+			return num(v(h)), true
+		}
+	}
+	def("tcp.SrcPort", kindNumber, 16, tcp(func(h *packet.TCP) uint64 { return uint64(h.SrcPort) }))
+	def("tcp.DstPort", kindNumber, 16, tcp(func(h *packet.TCP) uint64 { return uint64(h.DstPort) }))
+	def("tcp.SeqNum", kindNumber, 32, tcp(func(h *packet.TCP) uint64 { return uint64(h.Seq) }))
+	def("tcp.AckNum", kindNumber, 32, tcp(func(h *packet.TCP) uint64 { return uint64(h.Ack) }))
+	def("tcp.HdrLength", kindNumber, 4, tcp(func(h *packet.TCP) uint64 { return uint64(h.DataOffset) }))
+	for name, bit := range map[string]packet.TCPFlags{
+		"tcp.Urg": packet.TCPUrg, "tcp.Ack": packet.TCPAck, "tcp.Psh": packet.TCPPsh,
+		"tcp.Rst": packet.TCPRst, "tcp.Syn": packet.TCPSyn, "tcp.Fin": packet.TCPFin,
+	} {
+		def(name, kindNumber, 1, tcp(func(h *packet.TCP) uint64 { return boolU(h.Flags&bit != 0) }))
+	}
+	def("tcp.Window", kindNumber, 16, tcp(func(h *packet.TCP) uint64 { return uint64(h.Window) }))
+	def("tcp.Checksum", kindNumber, 16, tcp(func(h *packet.TCP) uint64 { return uint64(h.Checksum) }))
+	def("tcp.UrgPtr", kindNumber, 16, tcp(func(h *packet.TCP) uint64 { return uint64(h.Urgent) }))
+
+	udp := func(v func(h *packet.UDP) uint64) func(e *env) (u128, bool) {
+		return func(e *env) (u128, bool) {
+			h, ok := e.udp()
+			if !ok {
+				return u128{}, false
+			}
+			return num(v(h)), true
+		}
+	}
+	def("udp.SrcPort", kindNumber, 16, udp(func(h *packet.UDP) uint64 { return uint64(h.SrcPort) }))
+	def("udp.DstPort", kindNumber, 16, udp(func(h *packet.UDP) uint64 { return uint64(h.DstPort) }))
+	def("udp.Length", kindNumber, 16, udp(func(h *packet.UDP) uint64 { return uint64(h.Length) }))
+	def("udp.Checksum", kindNumber, 16, udp(func(h *packet.UDP) uint64 { return uint64(h.Checksum) }))
+
+	for _, proto := range []string{"tcp", "udp"} {
+		present := func(e *env) bool { _, ok := e.tcp(); return ok }
+		if proto == "udp" {
+			present = func(e *env) bool { _, ok := e.udp(); return ok }
+		}
+		payload := func(e *env) ([]byte, bool) {
+			if !present(e) {
+				return nil, false
+			}
+			return e.pkt.Payload(), true
+		}
+		def(proto+".PayloadLength", kindNumber, 16, func(e *env) (u128, bool) {
+			b, ok := payload(e)
+			return num(uint64(len(b))), ok
+		})
+		defIndexed(proto+".Payload", 1, payload)
+		defIndexed(proto+".Payload16", 2, payload)
+		defIndexed(proto+".Payload32", 4, payload)
+	}
+}
+
+func boolU(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
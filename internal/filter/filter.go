@@ -0,0 +1,202 @@
+// Package filter parses and evaluates the WinDivert filter language, so filters can be
+// validated before a WinDivert handle is opened and applied to packets from devices
+// that do not filter for themselves, such as pcap replays.
+//
+// The grammar follows WinDivert 2.x at the network layer:
+//
+//	FILTER := TRUE | FALSE | TEST | not FILTER | FILTER and FILTER | FILTER or FILTER
+//	        | (FILTER) | FILTER ? FILTER : FILTER
+//	TEST   := FIELD | FIELD OP VALUE
+//	OP     := == | = | != | < | > | <= | >=
+//	VALUE  := number | IPv4 or IPv6 address | TRUE | FALSE | symbol (event == PACKET)
+//
+// "&&", "||" and "!" are accepted for and, or and not. A bare FIELD means FIELD != 0. A
+// test on a field the packet does not have (tcp.DstPort of a UDP packet) is false.
+package filter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"chaosblade-win/internal/packet"
+)
+
+// Filter is a parsed filter expression.
+type Filter struct {
+	src  string
+	root node
+}
+
+// Meta is the packet metadata filters can test besides the packet bytes.
+type Meta struct {
+	Outbound bool
+	Loopback bool
+	Impostor bool
+	IfIdx    uint32
+	SubIfIdx uint32
+	// Timestamp is the packet time as the device reports it; WinDivert uses
+	// performance-counter ticks, so filters rarely compare it with a constant.
+	Timestamp int64
+}
+
+// SyntaxError reports an invalid filter with the byte offset of the problem.
+type SyntaxError struct {
+	Filter string
+	Pos    int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos+1)
+}
+
+// Caret renders the filter with a marker under the error position.
+func (e *SyntaxError) Caret() string {
+	return e.Filter + "\n" + strings.Repeat(" ", e.Pos) + "^"
+}
+
+// Parse parses a WinDivert filter expression.
+func Parse(s string) (*Filter, error) {
+	p := &parser{src: s}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{src: s, root: root}, nil
+}
+
+// String returns the filter source.
+func (f *Filter) String() string {
+	return f.src
+}
+
+// Match reports whether the packet in data, an IP packet, passes the filter. Packets
+// that cannot be decoded only match tests on metadata and raw packet bytes.
+func (f *Filter) Match(data []byte, m Meta) bool {
+	p, err := packet.Decode(data)
+	if err != nil {
+		p = nil
+	}
+	return f.root.eval(&env{pkt: p, raw: data, meta: m})
+}
+
+// MatchPacket is Match for an already decoded packet.
+func (f *Filter) MatchPacket(p *packet.Packet, m Meta) bool {
+	return f.root.eval(&env{pkt: p, raw: p.Data, meta: m})
+}
+
+// env is the evaluation context of one packet.
+type env struct {
+	pkt  *packet.Packet
+	raw  []byte
+	meta Meta
+
+	rnd    uint32
+	rndSet bool
+}
+
+// random returns the packet's random value; random8, random16 and random32 are all
+// drawn from it, so every test on one packet sees the same number.
+func (e *env) random() uint32 {
+	if !e.rndSet {
+		e.rnd, e.rndSet = rand.Uint32(), true
+	}
+	return e.rnd
+}
+
+type node interface {
+	eval(e *env) bool
+}
+
+type constNode bool
+
+func (n constNode) eval(*env) bool { return bool(n) }
+
+type notNode struct{ x node }
+
+func (n notNode) eval(e *env) bool { return !n.x.eval(e) }
+
+type andNode struct{ a, b node }
+
+func (n andNode) eval(e *env) bool { return n.a.eval(e) && n.b.eval(e) }
+
+type orNode struct{ a, b node }
+
+func (n orNode) eval(e *env) bool { return n.a.eval(e) || n.b.eval(e) }
+
+type condNode struct{ cond, then, els node }
+
+func (n condNode) eval(e *env) bool {
+	if n.cond.eval(e) {
+		return n.then.eval(e)
+	}
+	return n.els.eval(e)
+}
+
+type op int
+
+const (
+	opEq op = iota
+	opNe
+	opLt
+	opGt
+	opLe
+	opGe
+)
+
+// testNode compares a field with a constant.
+type testNode struct {
+	field *field
+	index int
+	op    op
+	val   u128
+}
+
+func (n testNode) eval(e *env) bool {
+	v, ok := n.field.get(e, n.index)
+	if !ok {
+		return false
+	}
+	c := v.cmp(n.val)
+	switch n.op {
+	case opEq:
+		return c == 0
+	case opNe:
+		return c != 0
+	case opLt:
+		return c < 0
+	case opGt:
+		return c > 0
+	case opLe:
+		return c <= 0
+	default:
+		return c >= 0
+	}
+}
+
+// u128 is an unsigned 128-bit value, wide enough for IPv6 addresses.
+type u128 struct{ hi, lo uint64 }
+
+func (a u128) cmp(b u128) int {
+	switch {
+	case a.hi < b.hi:
+		return -1
+	case a.hi > b.hi:
+		return 1
+	case a.lo < b.lo:
+		return -1
+	case a.lo > b.lo:
+		return 1
+	}
+	return 0
+}
+
+func num(v uint64) u128 { return u128{lo: v} }
+
+func boolVal(b bool) u128 {
+	if b {
+		return num(1)
+	}
+	return num(0)
+}
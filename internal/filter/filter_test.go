@@ -0,0 +1,199 @@
+package filter
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test packets between 10.0.0.1 / 2001:db8::1 (local when outbound) and
+// 93.184.216.34 / 2001:db8::2.
+var (
+	tcpSyn = mustHex("450000300001000040063aec0a0000015db8d822c00001bb000003e8000000007002faf083a80000020405b401030307")
+	udpDNS = mustHex("450000390001000040113ad80a0000015db8d8229c4000350025a817abcd01000001000000000000076578616d706c6503636f6d0000010001")
+	icmpV4 = mustHex("4500002c0001000040013af50a0000015db8d8220800a27f123400016162636465666768696a6b6c6d6e6f70")
+	tcpV6  = mustHex("600000000019064020010db800000000000000000000000120010db8000000000000000000000002c35001bb0000004d000000585010faf04fe7000068656c6c6f")
+	icmpV6 = mustHex("60000000000c3a4020010db800000000000000000000000120010db80000000000000000000000028000456b0007000170696e67")
+	frag   = mustHex("4500001c0007000240113aed0a0000015db8d8223839616263646566")
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParseAccepts(t *testing.T) {
+	for _, expr := range []string{
+		"true",
+		"outbound and tcp",
+		"OUTBOUND AND TCP.DstPort == 443",
+		"outbound && !udp || icmp",
+		"(tcp or udp) and not loopback",
+		"tcp.DstPort = 80",
+		"ip.DstAddr >= 10.0.0.0 and ip.DstAddr <= 10.255.255.255",
+		"ipv6.DstAddr == 2001:db8::2",
+		"remoteAddr == ::ffff:93.184.216.34",
+		"localAddr == 10.0.0.1",
+		"tcp ? tcp.DstPort == 443 : udp.DstPort == 53",
+		"ipv6 ? ipv6.DstAddr == 2001:db8:: : true",
+		"packet[0] == 0x45 and packet16[-1] != 0 and packet32[4] > 0",
+		"udp.Payload[0] == 0xab and udp.PayloadLength > 4",
+		"tcp.Syn and not tcp.Ack",
+		"event == PACKET and layer == NETWORK",
+		"event == connect or layer == NETWORK_FORWARD",
+		"random8 < 128 and random16 <= 65535 and random32 >= 0",
+		"timestamp > 0",
+		"ifIdx == 3 and subIfIdx == 0",
+		"icmpv6.Type == 128",
+		"zero == 0",
+	} {
+		if _, err := Parse(expr); err != nil {
+			t.Errorf("Parse(%q): %v", expr, err)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"", 1, "empty filter"},
+		{"outbound and", 13, "unexpected end"},
+		{"tcp.DstPrt == 80", 1, `unknown field "tcp.DstPrt"`},
+		{"tcp.DstPort == 70000", 16, "too large"},
+		{"ip.DstAddr == 2001:db8::1", 15, "needs an IPv4 address"},
+		{"ipv6.SrcAddr == 10.0.0.1", 17, "needs an IPv6 address"},
+		{"(tcp", 5, `expected ")"`},
+		{"tcp ? udp", 10, `expected ":"`},
+		{"packet == 1", 7, "needs an index"},
+		{"tcp[0]", 4, "not indexed"},
+		{"tcp.DstPort ==", 15, "expected value"},
+		{"event == SOMETHING", 10, "invalid value"},
+		{"processId == 4", 1, "not available at the network layer"},
+		{"tcp udp", 5, "unexpected"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Parse(%q) = %v, want a SyntaxError", tt.expr, err)
+			continue
+		}
+		if se.Pos+1 != tt.pos || !strings.Contains(se.Msg, tt.msg) {
+			t.Errorf("Parse(%q) = %q at %d, want %q at %d", tt.expr, se.Msg, se.Pos+1, tt.msg, tt.pos)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	out := Meta{Outbound: true}
+	in := Meta{}
+	tests := []struct {
+		expr string
+		pkt  []byte
+		meta Meta
+		want bool
+	}{
+		{"outbound and tcp", tcpSyn, out, true},
+		{"outbound and tcp", tcpSyn, in, false},
+		{"outbound and tcp", udpDNS, out, false},
+		{"tcp.DstPort == 443 and tcp.Syn and not tcp.Ack", tcpSyn, out, true},
+		{"udp.DstPort == 53 and udp.Payload[0] == 0xab and udp.Payload16[1] == 0x0100", udpDNS, out, true},
+		{"udp.PayloadLength == 29", udpDNS, out, true},
+		{"tcp.DstPort == 53", udpDNS, out, false},
+		{"not tcp.DstPort == 53", udpDNS, out, true},
+		{"ip.DstAddr == 93.184.216.34 and ip.TTL == 64", icmpV4, out, true},
+		{"icmp.Type == 8 and icmp.Body == 0x12340001", icmpV4, out, true},
+		{"remotePort == 443 and localPort == 49152", tcpSyn, out, true},
+		{"remotePort == 49152", tcpSyn, in, true},
+		{"remoteAddr == 93.184.216.34", tcpSyn, out, true},
+		{"remoteAddr == 10.0.0.1", tcpSyn, in, true},
+		{"ipv6 and tcp.DstPort == 443 and tcp.PayloadLength == 5", tcpV6, out, true},
+		{"ipv6.DstAddr == 2001:db8::2 and remoteAddr == 2001:db8::2", tcpV6, out, true},
+		{"ipv6.DstAddr >= 2001:db8:: and ipv6.DstAddr <= 2001:db8::ffff", tcpV6, out, true},
+		{"icmpv6.Type == 128 and not icmp", icmpV6, out, true},
+		{"ip ? ip.TTL == 64 : ipv6.HopLimit == 64", tcpV6, out, true},
+		{"tcp ? tcp.DstPort == 80 : true", udpDNS, out, true},
+		{"tcp ? tcp.DstPort == 80 : true", tcpSyn, out, false},
+		{"packet[0] == 0x45 and packet[-1] == 0x07 and packet16[1] == 48", tcpSyn, out, true},
+		{"packet32[100] == 0", tcpSyn, out, false},
+		{"length == 48", tcpSyn, out, true},
+		{"fragment and not udp and udp.DstPort != 9999", frag, out, false},
+		{"fragment and not udp and ip.FragOff == 2", frag, out, true},
+		{"protocol == 17", frag, out, true},
+		{"event == PACKET and layer == NETWORK", udpDNS, out, true},
+		{"event == CONNECT or layer == FLOW", udpDNS, out, false},
+		{"loopback", tcpSyn, Meta{Outbound: true, Loopback: true}, true},
+		{"ifIdx == 7", tcpSyn, Meta{IfIdx: 7}, true},
+		{"timestamp == 12345", tcpSyn, Meta{Timestamp: 12345}, true},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(tt.pkt, tt.meta); got != tt.want {
+			t.Errorf("%q on %x (%+v) = %v, want %v", tt.expr, tt.pkt[:min(len(tt.pkt), 20)], tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestMatchUndecodable(t *testing.T) {
+	junk := []byte{0x45, 0x00, 0xff}
+	for expr, want := range map[string]bool{
+		"outbound":          true,
+		"packet[0] == 0x45": true,
+		"tcp":               false,
+		"not tcp":           true,
+		"ip.TTL == 0":       false,
+		"length == 3":       true,
+		"remotePort == 0":   false,
+		"event == PACKET":   true,
+	} {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Match(junk, Meta{Outbound: true}); got != want {
+			t.Errorf("%q on an undecodable packet = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+// TestRandomFields checks random8 samples roughly uniformly and that the random fields
+// of one packet share a value.
+func TestRandomFields(t *testing.T) {
+	half, err := Parse("random8 < 128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each holds for every packet only if both tests see the same number.
+	same, err := Parse("random8 < 128 or random8 >= 128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := Parse("not (random16 < 128 and random8 >= 128)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 20000
+	matched := 0
+	for i := 0; i < n; i++ {
+		if half.Match(tcpSyn, Meta{}) {
+			matched++
+		}
+		if !same.Match(tcpSyn, Meta{}) || !shared.Match(tcpSyn, Meta{}) {
+			t.Fatal("random fields differ within one packet")
+		}
+	}
+	if matched < n*45/100 || matched > n*55/100 {
+		t.Errorf("random8 < 128 matched %d of %d packets", matched, n)
+	}
+}
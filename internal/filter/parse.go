@@ -0,0 +1,341 @@
+package filter
+
+import (
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Filter: p.src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parse() (node, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf(p.pos, "empty filter")
+	}
+	n, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf(p.pos, "unexpected %q", p.rest())
+	}
+	return n, nil
+}
+
+// rest returns a short excerpt from the current position for error messages.
+func (p *parser) rest() string {
+	r := p.src[p.pos:]
+	if end := strings.IndexAny(r, " \t\r\n"); end > 0 {
+		r = r[:end]
+	}
+	if len(r) > 16 {
+		r = r[:16] + "..."
+	}
+	return r
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// accept consumes one of the given symbols or case-insensitive keywords.
+func (p *parser) accept(tokens ...string) bool {
+	p.skipSpace()
+	for _, t := range tokens {
+		end := p.pos + len(t)
+		if end > len(p.src) || !strings.EqualFold(p.src[p.pos:end], t) {
+			continue
+		}
+		if isIdentByte(t[0]) && end < len(p.src) && isIdentByte(p.src[end]) {
+			continue // a keyword must not run into an identifier, e.g. "android"
+		}
+		p.pos = end
+		return true
+	}
+	return false
+}
+
+// parseCond parses the ternary operator, which binds loosest.
+func (p *parser) parseCond() (node, error) {
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return c, nil
+	}
+	then, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(":") {
+		return nil, p.errorf(p.pos, "expected \":\" in conditional")
+	}
+	els, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	return condNode{c, then, els}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	a, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		b, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		a = orNode{a, b}
+	}
+	return a, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	a, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		b, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		a = andNode{a, b}
+	}
+	return a, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("not") || p.acceptBang() {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// acceptBang consumes a "!" that is not the start of "!=".
+func (p *parser) acceptBang() bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '!' && (p.pos+1 == len(p.src) || p.src[p.pos+1] != '=') {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf(p.pos, "unexpected end of filter")
+	}
+	if p.accept("(") {
+		n, err := p.parseCond()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf(p.pos, "expected \")\"")
+		}
+		return n, nil
+	}
+
+	start := p.pos
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf(start, "expected field, \"(\" or \"not\", found %q", p.rest())
+	}
+	switch strings.ToLower(name) {
+	case "true":
+		return constNode(true), nil
+	case "false":
+		return constNode(false), nil
+	}
+	f := lookupField(name)
+	if f == nil {
+		if otherLayerFields[strings.ToLower(name)] {
+			return nil, p.errorf(start, "field %q is not available at the network layer", name)
+		}
+		return nil, p.errorf(start, "unknown field %q", name)
+	}
+
+	t := testNode{field: f}
+	if f.indexed {
+		idx, err := p.parseIndex(name)
+		if err != nil {
+			return nil, err
+		}
+		t.index = idx
+	} else if p.pos < len(p.src) && p.src[p.pos] == '[' {
+		return nil, p.errorf(p.pos, "field %q is not indexed", name)
+	}
+
+	opPos := p.pos
+	o, ok := p.parseOp()
+	if !ok {
+		// A bare field tests for non-zero.
+		t.op = opNe
+		return t, nil
+	}
+	t.op = o
+
+	p.skipSpace()
+	valPos := p.pos
+	lit := p.valueLiteral()
+	if lit == "" {
+		return nil, p.errorf(valPos, "expected value after %q", strings.TrimSpace(p.src[opPos:valPos]))
+	}
+	v, err := f.parseValue(lit)
+	if err != nil {
+		return nil, p.errorf(valPos, "%v", err)
+	}
+	t.val = v
+	return t, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '.' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// ident reads a field name or keyword starting with a letter.
+func (p *parser) ident() string {
+	start := p.pos
+	if p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return ""
+		}
+	}
+	for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseIndex reads "[N]" after an indexed field; negative N counts from the end.
+func (p *parser) parseIndex(name string) (int, error) {
+	if p.pos >= len(p.src) || p.src[p.pos] != '[' {
+		return 0, p.errorf(p.pos, "field %q needs an index, e.g. %s[0]", name, name)
+	}
+	p.pos++
+	start := p.pos
+	if p.pos < len(p.src) && p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	idx, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil || idx < -0xffff || idx > 0xffff {
+		return 0, p.errorf(start, "invalid index")
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+		return 0, p.errorf(p.pos, "expected \"]\"")
+	}
+	p.pos++
+	return idx, nil
+}
+
+func (p *parser) parseOp() (op, bool) {
+	switch {
+	case p.accept("=="), p.accept("="):
+		return opEq, true
+	case p.accept("!="):
+		return opNe, true
+	case p.accept("<="):
+		return opLe, true
+	case p.accept(">="):
+		return opGe, true
+	case p.accept("<"):
+		return opLt, true
+	case p.accept(">"):
+		return opGt, true
+	}
+	return 0, false
+}
+
+// valueLiteral reads a number or address. IPv6 addresses contain colons, so a single
+// trailing colon is left for the conditional operator.
+func (p *parser) valueLiteral() string {
+	start := p.pos
+	for p.pos < len(p.src) && (isIdentByte(p.src[p.pos]) || p.src[p.pos] == ':') {
+		p.pos++
+	}
+	lit := p.src[start:p.pos]
+	if strings.HasSuffix(lit, ":") && !strings.HasSuffix(lit, "::") {
+		p.pos--
+		lit = lit[:len(lit)-1]
+	}
+	return lit
+}
+
+// parseLiteral converts a literal to a value. Addresses are returned with their
+// family so fields can reject the wrong one.
+func parseLiteral(lit string) (v u128, addr netip.Addr, err error) {
+	switch strings.ToLower(lit) {
+	case "true":
+		return num(1), addr, nil
+	case "false":
+		return num(0), addr, nil
+	}
+	if strings.ContainsAny(lit, ".:") {
+		a, err := netip.ParseAddr(lit)
+		if err != nil || a.Zone() != "" {
+			return v, addr, fmt.Errorf("invalid address %q", lit)
+		}
+		return addrValue(a), a, nil
+	}
+	base, digits := 10, lit
+	if len(lit) > 2 && (lit[:2] == "0x" || lit[:2] == "0X") {
+		base, digits = 16, lit[2:]
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return v, addr, fmt.Errorf("invalid value %q", lit)
+	}
+	return num(n), addr, nil
+}
+
+// addrValue is the 128-bit form of a; IPv4 addresses are plain 32-bit values.
+func addrValue(a netip.Addr) u128 {
+	if a.Is4() {
+		b := a.As4()
+		return num(uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3]))
+	}
+	b := a.As16()
+	var v u128
+	for i := range 8 {
+		v.hi = v.hi<<8 | uint64(b[i])
+		v.lo = v.lo<<8 | uint64(b[i+8])
+	}
+	return v
+}
+
+// fits reports whether v fits in n bits.
+func (v u128) fits(n int) bool {
+	if n >= 128 {
+		return true
+	}
+	if n >= 64 {
+		return bits.Len64(v.hi) <= n-64
+	}
+	return v.hi == 0 && bits.Len64(v.lo) <= n
+}
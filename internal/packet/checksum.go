@@ -0,0 +1,54 @@
+package packet
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// sum adds b to a running one's-complement sum as big-endian 16-bit words.
+func sum(acc uint32, b []byte) uint32 {
+	for len(b) >= 2 {
+		acc += uint32(binary.BigEndian.Uint16(b))
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		acc += uint32(b[0]) << 8
+	}
+	return acc
+}
+
+// fold reduces a running sum to the final 16-bit one's-complement checksum.
+func fold(acc uint32) uint16 {
+	for acc>>16 != 0 {
+		acc = acc&0xffff + acc>>16
+	}
+	return ^uint16(acc)
+}
+
+// Checksum computes the Internet checksum (RFC 1071) of b.
+func Checksum(b []byte) uint16 {
+	return fold(sum(0, b))
+}
+
+// pseudoHeaderSum returns the partial sum of the TCP/UDP/ICMPv6 pseudo-header for a
+// segment of length bytes carried between src and dst.
+func pseudoHeaderSum(src, dst netip.Addr, proto Protocol, length int) uint32 {
+	var acc uint32
+	s, d := src.AsSlice(), dst.AsSlice()
+	acc = sum(acc, s)
+	acc = sum(acc, d)
+	if src.Is4() {
+		acc += uint32(proto)
+		acc += uint32(length)
+	} else {
+		acc += uint32(length>>16) + uint32(length&0xffff)
+		acc += uint32(proto)
+	}
+	return acc
+}
+
+// transportChecksum computes the checksum of segment including the pseudo-header.
+// The checksum field inside segment must be zeroed by the caller.
+func transportChecksum(src, dst netip.Addr, proto Protocol, segment []byte) uint16 {
+	return fold(sum(pseudoHeaderSum(src, dst, proto, len(segment)), segment))
+}
@@ -0,0 +1,39 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ICMPHeaderLen is the length of the ICMP and ICMPv6 header.
+const ICMPHeaderLen = 8
+
+// ICMP is a decoded ICMP or ICMPv6 header. Rest holds the type-specific word, such as
+// the identifier and sequence number of an echo request.
+type ICMP struct {
+	Type, Code uint8
+	Checksum   uint16
+	Rest       [4]byte
+}
+
+// Decode parses an ICMP header from the start of b.
+func (h *ICMP) Decode(b []byte) error {
+	if len(b) < ICMPHeaderLen {
+		return fmt.Errorf("%w: ICMP header needs %d bytes, have %d", ErrTruncated, ICMPHeaderLen, len(b))
+	}
+	h.Type, h.Code = b[0], b[1]
+	h.Checksum = binary.BigEndian.Uint16(b[2:])
+	copy(h.Rest[:], b[4:8])
+	return nil
+}
+
+// Encode writes the header into the start of b.
+func (h *ICMP) Encode(b []byte) error {
+	if len(b) < ICMPHeaderLen {
+		return fmt.Errorf("%w: need %d bytes to encode ICMP header", ErrTruncated, ICMPHeaderLen)
+	}
+	b[0], b[1] = h.Type, h.Code
+	binary.BigEndian.PutUint16(b[2:], h.Checksum)
+	copy(b[4:8], h.Rest[:])
+	return nil
+}
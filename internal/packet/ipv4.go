@@ -0,0 +1,113 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// IPv4HeaderLen is the length of an IPv4 header without options.
+const IPv4HeaderLen = 20
+
+// IPv4 fragment flags.
+const (
+	IPv4DontFragment  = 0x2
+	IPv4MoreFragments = 0x1
+)
+
+// IPv4 is a decoded IPv4 header.
+type IPv4 struct {
+	IHL        uint8 // header length in 32-bit words
+	TOS        uint8
+	Length     uint16 // total length, header included
+	ID         uint16
+	Flags      uint8  // IPv4DontFragment, IPv4MoreFragments
+	FragOffset uint16 // in 8-byte units
+	TTL        uint8
+	Protocol   Protocol
+	Checksum   uint16
+	Src, Dst   netip.Addr
+	Options    []byte
+}
+
+// HeaderLen returns the header length in bytes.
+func (h *IPv4) HeaderLen() int {
+	return int(h.IHL) * 4
+}
+
+// Decode parses an IPv4 header from the start of b.
+func (h *IPv4) Decode(b []byte) error {
+	if len(b) < IPv4HeaderLen {
+		return fmt.Errorf("%w: IPv4 header needs %d bytes, have %d", ErrTruncated, IPv4HeaderLen, len(b))
+	}
+	if v := b[0] >> 4; v != 4 {
+		return fmt.Errorf("%w: IP version %d in IPv4 header", ErrMalformed, v)
+	}
+	h.IHL = b[0] & 0x0f
+	hl := h.HeaderLen()
+	if hl < IPv4HeaderLen {
+		return fmt.Errorf("%w: IPv4 header length %d", ErrMalformed, hl)
+	}
+	if len(b) < hl {
+		return fmt.Errorf("%w: IPv4 header with options needs %d bytes, have %d", ErrTruncated, hl, len(b))
+	}
+	h.TOS = b[1]
+	h.Length = binary.BigEndian.Uint16(b[2:])
+	if int(h.Length) < hl {
+		return fmt.Errorf("%w: IPv4 total length %d shorter than header", ErrMalformed, h.Length)
+	}
+	h.ID = binary.BigEndian.Uint16(b[4:])
+	frag := binary.BigEndian.Uint16(b[6:])
+	h.Flags = uint8(frag >> 13)
+	h.FragOffset = frag & 0x1fff
+	h.TTL = b[8]
+	h.Protocol = Protocol(b[9])
+	h.Checksum = binary.BigEndian.Uint16(b[10:])
+	h.Src = netip.AddrFrom4([4]byte(b[12:16]))
+	h.Dst = netip.AddrFrom4([4]byte(b[16:20]))
+	h.Options = b[IPv4HeaderLen:hl]
+	return nil
+}
+
+// Encode writes the header into the start of b, which must hold HeaderLen bytes.
+// The checksum is written as stored; call UpdateChecksum to recompute it.
+func (h *IPv4) Encode(b []byte) error {
+	hl := IPv4HeaderLen + len(h.Options)
+	if hl%4 != 0 || hl > 60 {
+		return fmt.Errorf("%w: IPv4 options must pad the header to a multiple of 4 up to 60 bytes", ErrMalformed)
+	}
+	if len(b) < hl {
+		return fmt.Errorf("%w: need %d bytes to encode IPv4 header", ErrTruncated, hl)
+	}
+	if !h.Src.Is4() || !h.Dst.Is4() {
+		return fmt.Errorf("%w: IPv4 header needs IPv4 addresses", ErrMalformed)
+	}
+	h.IHL = uint8(hl / 4)
+	b[0] = 4<<4 | h.IHL
+	b[1] = h.TOS
+	binary.BigEndian.PutUint16(b[2:], h.Length)
+	binary.BigEndian.PutUint16(b[4:], h.ID)
+	binary.BigEndian.PutUint16(b[6:], uint16(h.Flags&0x7)<<13|h.FragOffset&0x1fff)
+	b[8] = h.TTL
+	b[9] = byte(h.Protocol)
+	binary.BigEndian.PutUint16(b[10:], h.Checksum)
+	src, dst := h.Src.As4(), h.Dst.As4()
+	copy(b[12:16], src[:])
+	copy(b[16:20], dst[:])
+	copy(b[IPv4HeaderLen:hl], h.Options)
+	return nil
+}
+
+// UpdateChecksum recomputes the header checksum of an encoded header at the start of b
+// and stores it in both b and h.
+func (h *IPv4) UpdateChecksum(b []byte) {
+	hl := h.HeaderLen()
+	binary.BigEndian.PutUint16(b[10:], 0)
+	h.Checksum = Checksum(b[:hl])
+	binary.BigEndian.PutUint16(b[10:], h.Checksum)
+}
+
+// IsFragment reports whether the packet is part of a fragmented datagram.
+func (h *IPv4) IsFragment() bool {
+	return h.Flags&IPv4MoreFragments != 0 || h.FragOffset != 0
+}
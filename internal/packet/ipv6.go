@@ -0,0 +1,107 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// IPv6HeaderLen is the length of the fixed IPv6 header.
+const IPv6HeaderLen = 40
+
+// IPv6 extension header protocol numbers skipped when locating the transport header.
+const (
+	ipv6HopByHop Protocol = 0
+	ipv6Routing  Protocol = 43
+	ipv6Fragment Protocol = 44
+	ipv6AH       Protocol = 51
+	ipv6DestOpts Protocol = 60
+	ipv6NoNext   Protocol = 59
+)
+
+// IPv6 is a decoded fixed IPv6 header.
+type IPv6 struct {
+	TrafficClass  uint8
+	FlowLabel     uint32
+	PayloadLength uint16
+	NextHeader    Protocol // first header after the fixed header, possibly an extension
+	HopLimit      uint8
+	Src, Dst      netip.Addr
+}
+
+// Decode parses the fixed IPv6 header from the start of b.
+func (h *IPv6) Decode(b []byte) error {
+	if len(b) < IPv6HeaderLen {
+		return fmt.Errorf("%w: IPv6 header needs %d bytes, have %d", ErrTruncated, IPv6HeaderLen, len(b))
+	}
+	if v := b[0] >> 4; v != 6 {
+		return fmt.Errorf("%w: IP version %d in IPv6 header", ErrMalformed, v)
+	}
+	word := binary.BigEndian.Uint32(b)
+	h.TrafficClass = uint8(word >> 20)
+	h.FlowLabel = word & 0xfffff
+	h.PayloadLength = binary.BigEndian.Uint16(b[4:])
+	h.NextHeader = Protocol(b[6])
+	h.HopLimit = b[7]
+	h.Src = netip.AddrFrom16([16]byte(b[8:24]))
+	h.Dst = netip.AddrFrom16([16]byte(b[24:40]))
+	return nil
+}
+
+// Encode writes the fixed header into the start of b.
+func (h *IPv6) Encode(b []byte) error {
+	if len(b) < IPv6HeaderLen {
+		return fmt.Errorf("%w: need %d bytes to encode IPv6 header", ErrTruncated, IPv6HeaderLen)
+	}
+	if !h.Src.Is6() || !h.Dst.Is6() {
+		return fmt.Errorf("%w: IPv6 header needs IPv6 addresses", ErrMalformed)
+	}
+	binary.BigEndian.PutUint32(b, 6<<28|uint32(h.TrafficClass)<<20|h.FlowLabel&0xfffff)
+	binary.BigEndian.PutUint16(b[4:], h.PayloadLength)
+	b[6] = byte(h.NextHeader)
+	b[7] = h.HopLimit
+	src, dst := h.Src.As16(), h.Dst.As16()
+	copy(b[8:24], src[:])
+	copy(b[24:40], dst[:])
+	return nil
+}
+
+// skipExtensions follows the extension header chain in payload starting at next and
+// returns the upper-layer protocol and its offset within payload. ok is false for
+// non-first fragments, ESP or truncated chains, where no transport header is visible;
+// frag reports whether a fragment header was seen.
+func skipExtensions(next Protocol, payload []byte) (proto Protocol, off int, ok, frag bool) {
+	for {
+		switch next {
+		case ipv6HopByHop, ipv6Routing, ipv6DestOpts:
+			if len(payload) < off+8 {
+				return next, off, false, frag
+			}
+			n := Protocol(payload[off])
+			off += (int(payload[off+1]) + 1) * 8
+			next = n
+		case ipv6AH:
+			if len(payload) < off+8 {
+				return next, off, false, frag
+			}
+			n := Protocol(payload[off])
+			off += (int(payload[off+1]) + 2) * 4
+			next = n
+		case ipv6Fragment:
+			if len(payload) < off+8 {
+				return next, off, false, frag
+			}
+			n := Protocol(payload[off])
+			fragOffset := binary.BigEndian.Uint16(payload[off+2:]) >> 3
+			off += 8
+			next, frag = n, true
+			if fragOffset != 0 {
+				return next, off, false, frag
+			}
+		case ipv6NoNext:
+			return next, off, false, frag
+		default:
+			return next, off, off <= len(payload), frag
+		}
+	}
+}
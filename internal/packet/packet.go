@@ -0,0 +1,320 @@
+// Package packet decodes and encodes the IPv4, IPv6, TCP, UDP and ICMP headers of raw
+// IP packets, as delivered by WinDivert or read from a capture, and recomputes their
+// checksums after a packet has been modified.
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Protocol is an IP protocol number.
+type Protocol uint8
+
+// Transport protocols understood by Decode.
+const (
+	ProtoICMP   Protocol = 1
+	ProtoTCP    Protocol = 6
+	ProtoUDP    Protocol = 17
+	ProtoICMPv6 Protocol = 58
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtoICMP:
+		return "icmp"
+	case ProtoTCP:
+		return "tcp"
+	case ProtoUDP:
+		return "udp"
+	case ProtoICMPv6:
+		return "icmpv6"
+	default:
+		return fmt.Sprintf("proto-%d", uint8(p))
+	}
+}
+
+var (
+	// ErrTruncated is returned when a header extends past the end of the packet.
+	ErrTruncated = errors.New("packet truncated")
+	// ErrMalformed is returned for header fields that cannot be valid.
+	ErrMalformed = errors.New("malformed packet")
+)
+
+// FiveTuple identifies the flow a packet belongs to. Ports are zero for ICMP and for
+// packets whose transport header is not visible.
+type FiveTuple struct {
+	Protocol         Protocol
+	Src, Dst         netip.Addr
+	SrcPort, DstPort uint16
+}
+
+func (t FiveTuple) String() string {
+	return fmt.Sprintf("%s %s -> %s", t.Protocol,
+		netip.AddrPortFrom(t.Src, t.SrcPort), netip.AddrPortFrom(t.Dst, t.DstPort))
+}
+
+// Packet is a decoded IP packet. The header structs are views of Data: after changing
+// a field, call Encode to write the headers back and RecomputeChecksums to fix them up.
+// Exactly one of IPv4 and IPv6 is set; at most one of TCP, UDP and ICMP is.
+type Packet struct {
+	Data []byte
+
+	IPv4 *IPv4
+	IPv6 *IPv6
+	TCP  *TCP
+	UDP  *UDP
+	ICMP *ICMP
+
+	// Protocol is the upper-layer protocol, after any IPv6 extension headers.
+	Protocol Protocol
+	// TransportOffset is where the transport header starts, or -1 when it is not
+	// visible (non-first fragments, unknown or truncated extension chains).
+	TransportOffset int
+	// PayloadOffset is where the transport payload starts, or -1.
+	PayloadOffset int
+	// End is the end of the IP packet within Data, excluding link-layer padding.
+	End int
+
+	ipv6Fragment bool
+}
+
+// Decode parses the IP and transport headers of data, which must start with the IP
+// header. Unknown transport protocols are not an error; their header fields are nil.
+func Decode(data []byte) (*Packet, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty packet", ErrTruncated)
+	}
+	p := &Packet{Data: data, TransportOffset: -1, PayloadOffset: -1}
+	switch v := data[0] >> 4; v {
+	case 4:
+		h := &IPv4{}
+		if err := h.Decode(data); err != nil {
+			return nil, err
+		}
+		if int(h.Length) > len(data) {
+			return nil, fmt.Errorf("%w: IPv4 total length %d, have %d bytes", ErrTruncated, h.Length, len(data))
+		}
+		p.IPv4, p.Protocol, p.End = h, h.Protocol, int(h.Length)
+		if h.FragOffset == 0 {
+			p.TransportOffset = h.HeaderLen()
+		}
+	case 6:
+		h := &IPv6{}
+		if err := h.Decode(data); err != nil {
+			return nil, err
+		}
+		p.End = IPv6HeaderLen + int(h.PayloadLength)
+		if p.End > len(data) {
+			return nil, fmt.Errorf("%w: IPv6 payload length %d, have %d bytes", ErrTruncated, h.PayloadLength, len(data)-IPv6HeaderLen)
+		}
+		p.IPv6 = h
+		proto, off, ok, frag := skipExtensions(h.NextHeader, data[IPv6HeaderLen:p.End])
+		p.Protocol, p.ipv6Fragment = proto, frag
+		if ok {
+			p.TransportOffset = IPv6HeaderLen + off
+		}
+	default:
+		return nil, fmt.Errorf("%w: IP version %d", ErrMalformed, v)
+	}
+	if p.TransportOffset < 0 {
+		return p, nil
+	}
+	if err := p.decodeTransport(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Packet) decodeTransport() error {
+	seg := p.Data[p.TransportOffset:p.End]
+	switch p.Protocol {
+	case ProtoTCP:
+		h := &TCP{}
+		if err := h.Decode(seg); err != nil {
+			return err
+		}
+		p.TCP, p.PayloadOffset = h, p.TransportOffset+h.HeaderLen()
+	case ProtoUDP:
+		h := &UDP{}
+		if err := h.Decode(seg); err != nil {
+			return err
+		}
+		p.UDP, p.PayloadOffset = h, p.TransportOffset+UDPHeaderLen
+	case ProtoICMP, ProtoICMPv6:
+		h := &ICMP{}
+		if err := h.Decode(seg); err != nil {
+			return err
+		}
+		p.ICMP, p.PayloadOffset = h, p.TransportOffset+ICMPHeaderLen
+	}
+	return nil
+}
+
+// Src returns the source address.
+func (p *Packet) Src() netip.Addr {
+	if p.IPv4 != nil {
+		return p.IPv4.Src
+	}
+	return p.IPv6.Src
+}
+
+// Dst returns the destination address.
+func (p *Packet) Dst() netip.Addr {
+	if p.IPv4 != nil {
+		return p.IPv4.Dst
+	}
+	return p.IPv6.Dst
+}
+
+// FiveTuple returns the packet's flow identifier.
+func (p *Packet) FiveTuple() FiveTuple {
+	t := FiveTuple{Protocol: p.Protocol, Src: p.Src(), Dst: p.Dst()}
+	switch {
+	case p.TCP != nil:
+		t.SrcPort, t.DstPort = p.TCP.SrcPort, p.TCP.DstPort
+	case p.UDP != nil:
+		t.SrcPort, t.DstPort = p.UDP.SrcPort, p.UDP.DstPort
+	}
+	return t
+}
+
+// IPHeaderLen returns the length of the IP header, including IPv6 extension headers
+// when the transport header is visible.
+func (p *Packet) IPHeaderLen() int {
+	if p.TransportOffset >= 0 {
+		return p.TransportOffset
+	}
+	if p.IPv4 != nil {
+		return p.IPv4.HeaderLen()
+	}
+	return IPv6HeaderLen
+}
+
+// Payload returns the transport payload, or the IP payload when there is no decoded
+// transport header.
+func (p *Packet) Payload() []byte {
+	if p.PayloadOffset >= 0 {
+		return p.Data[p.PayloadOffset:p.End]
+	}
+	return p.Data[p.IPHeaderLen():p.End]
+}
+
+// Encode writes the decoded header fields back into Data. Header lengths must not
+// change, since the payload is not moved.
+func (p *Packet) Encode() error {
+	if p.IPv4 != nil {
+		if IPv4HeaderLen+len(p.IPv4.Options) != p.IPv4.HeaderLen() {
+			return fmt.Errorf("%w: IPv4 options changed length", ErrMalformed)
+		}
+		if err := p.IPv4.Encode(p.Data); err != nil {
+			return err
+		}
+	} else if err := p.IPv6.Encode(p.Data); err != nil {
+		return err
+	}
+	if p.TransportOffset < 0 {
+		return nil
+	}
+	seg := p.Data[p.TransportOffset:p.End]
+	switch {
+	case p.TCP != nil:
+		if TCPHeaderLen+len(p.TCP.Options) != p.TCP.HeaderLen() {
+			return fmt.Errorf("%w: TCP options changed length", ErrMalformed)
+		}
+		return p.TCP.Encode(seg)
+	case p.UDP != nil:
+		return p.UDP.Encode(seg)
+	case p.ICMP != nil:
+		return p.ICMP.Encode(seg)
+	}
+	return nil
+}
+
+// RecomputeChecksums recomputes the IPv4 header checksum and the TCP, UDP, ICMP or
+// ICMPv6 checksum in Data and in the decoded headers. Transport checksums of fragmented
+// datagrams are left alone since they cover bytes that are not in this packet.
+func (p *Packet) RecomputeChecksums() {
+	if p.IPv4 != nil {
+		p.IPv4.UpdateChecksum(p.Data)
+		if p.IPv4.IsFragment() {
+			return
+		}
+	} else if p.ipv6Fragment {
+		return
+	}
+	if p.TransportOffset < 0 {
+		return
+	}
+	seg := p.Data[p.TransportOffset:p.End]
+	switch {
+	case p.TCP != nil:
+		p.TCP.Checksum = p.updateChecksum(seg, 16, true)
+	case p.UDP != nil:
+		p.UDP.Checksum = p.updateChecksum(seg, 6, true)
+		if p.UDP.Checksum == 0 {
+			// Zero means "no checksum" for UDP; the all-ones form is sent instead.
+			p.UDP.Checksum = 0xffff
+			seg[6], seg[7] = 0xff, 0xff
+		}
+	case p.ICMP != nil:
+		p.ICMP.Checksum = p.updateChecksum(seg, 2, p.Protocol == ProtoICMPv6)
+	}
+}
+
+// updateChecksum zeroes the checksum at off within seg, computes it with or without
+// the pseudo-header and stores it.
+func (p *Packet) updateChecksum(seg []byte, off int, pseudo bool) uint16 {
+	seg[off], seg[off+1] = 0, 0
+	var c uint16
+	if pseudo {
+		c = transportChecksum(p.Src(), p.Dst(), p.Protocol, seg)
+	} else {
+		c = Checksum(seg)
+	}
+	seg[off], seg[off+1] = byte(c>>8), byte(c)
+	return c
+}
+
+// IsFragment reports whether the packet is part of a fragmented datagram.
+func (p *Packet) IsFragment() bool {
+	if p.IPv4 != nil {
+		return p.IPv4.IsFragment()
+	}
+	return p.ipv6Fragment
+}
+
+// ChecksumsValid reports whether the IPv4 header checksum and the transport checksum
+// verify. Parts that cannot be checked, such as fragments, are treated as valid.
+func (p *Packet) ChecksumsValid() bool {
+	if p.IPv4 != nil {
+		if Checksum(p.Data[:p.IPv4.HeaderLen()]) != 0 {
+			return false
+		}
+		if p.IPv4.IsFragment() {
+			return true
+		}
+	} else if p.ipv6Fragment {
+		return true
+	}
+	if p.TransportOffset < 0 {
+		return true
+	}
+	seg := p.Data[p.TransportOffset:p.End]
+	switch {
+	case p.TCP != nil:
+		return fold(sum(pseudoHeaderSum(p.Src(), p.Dst(), p.Protocol, len(seg)), seg)) == 0
+	case p.UDP != nil:
+		if p.UDP.Checksum == 0 && p.IPv4 != nil {
+			return true
+		}
+		return fold(sum(pseudoHeaderSum(p.Src(), p.Dst(), p.Protocol, len(seg)), seg)) == 0
+	case p.ICMP != nil:
+		if p.Protocol == ProtoICMPv6 {
+			return fold(sum(pseudoHeaderSum(p.Src(), p.Dst(), p.Protocol, len(seg)), seg)) == 0
+		}
+		return Checksum(seg) == 0
+	}
+	return true
+}
@@ -0,0 +1,328 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// Frames captured between 10.0.0.1 / 2001:db8::1 and 93.184.216.34 / 2001:db8::2, with
+// checksums as they appeared on the wire. The fragment pairs each carry one 24-byte UDP
+// datagram whose checksum covers both halves.
+var testFrames = map[string]string{
+	"ipv4TCPSyn":        "450000300001000040063aec0a0000015db8d822c00001bb000003e8000000007002faf083a80000020405b401030307",
+	"ipv4TCPOptions":    "4600003e000100004006a5d90a0000015db8d82294040000c001005000000005000000095018faf0d5ed0000474554202f20485454502f312e310d0a0d0a",
+	"ipv4UDP":           "450000390001000040113ad80a0000015db8d8229c4000350025a817abcd01000001000000000000076578616d706c6503636f6d0000010001",
+	"ipv4ICMP":          "4500002c0001000040013af50a0000015db8d8220800a27f123400016162636465666768696a6b6c6d6e6f70",
+	"ipv6TCP":           "600000000019064020010db800000000000000000000000120010db8000000000000000000000002c35001bb0000004d000000585010faf04fe7000068656c6c6f",
+	"ipv6UDP":           "60000000000d114020010db800000000000000000000000120010db80000000000000000000000029c410035000db8007175657279",
+	"ipv6ICMPv6":        "60000000000c3a4020010db800000000000000000000000120010db80000000000000000000000028000456b0007000170696e67",
+	"ipv6HopByHopUDP":   "600000000014004020010db800000000000000000000000120010db800000000000000000000000211000502000001009c4214e9000c175e6d646e73",
+	"ipv4FirstFragment": "450000240007200040111ae70a0000015db8d8229c43270f0018cd583031323334353637",
+	"ipv4LastFragment":  "4500001c0007000240113aed0a0000015db8d8223839616263646566",
+	"ipv6FirstFragment": "6000000000182c4020010db800000000000000000000000120010db800000000000000000000000211000001deadbeef9c44270f0018b1be3031323334353637",
+	"ipv6LastFragment":  "6000000000102c4020010db800000000000000000000000120010db800000000000000000000000211000010deadbeef3839616263646566",
+}
+
+func frame(t testing.TB, name string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(testFrames[name])
+	if err != nil || len(b) == 0 {
+		t.Fatalf("bad test frame %q", name)
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		frame     string
+		tuple     string
+		transport int
+		payload   string
+		fragment  bool
+	}{
+		{"ipv4TCPSyn", "tcp 10.0.0.1:49152 -> 93.184.216.34:443", 20, "", false},
+		{"ipv4TCPOptions", "tcp 10.0.0.1:49153 -> 93.184.216.34:80", 24, "GET / HTTP/1.1\r\n\r\n", false},
+		{"ipv4UDP", "udp 10.0.0.1:40000 -> 93.184.216.34:53", 20, "\xab\xcd\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07example\x03com\x00\x00\x01\x00\x01", false},
+		{"ipv4ICMP", "icmp 10.0.0.1:0 -> 93.184.216.34:0", 20, "abcdefghijklmnop", false},
+		{"ipv6TCP", "tcp [2001:db8::1]:50000 -> [2001:db8::2]:443", 40, "hello", false},
+		{"ipv6UDP", "udp [2001:db8::1]:40001 -> [2001:db8::2]:53", 40, "query", false},
+		{"ipv6ICMPv6", "icmpv6 [2001:db8::1]:0 -> [2001:db8::2]:0", 40, "ping", false},
+		{"ipv6HopByHopUDP", "udp [2001:db8::1]:40002 -> [2001:db8::2]:5353", 48, "mdns", false},
+		{"ipv4FirstFragment", "udp 10.0.0.1:40003 -> 93.184.216.34:9999", 20, "01234567", true},
+		{"ipv4LastFragment", "udp 10.0.0.1:0 -> 93.184.216.34:0", -1, "89abcdef", true},
+		{"ipv6FirstFragment", "udp [2001:db8::1]:40004 -> [2001:db8::2]:9999", 48, "01234567", true},
+		{"ipv6LastFragment", "udp [2001:db8::1]:0 -> [2001:db8::2]:0", -1, "\x11\x00\x00\x10\xde\xad\xbe\xef89abcdef", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.frame, func(t *testing.T) {
+			p, err := Decode(frame(t, tt.frame))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.FiveTuple().String(); got != tt.tuple {
+				t.Errorf("FiveTuple = %q, want %q", got, tt.tuple)
+			}
+			if p.TransportOffset != tt.transport {
+				t.Errorf("TransportOffset = %d, want %d", p.TransportOffset, tt.transport)
+			}
+			if got := string(p.Payload()); got != tt.payload {
+				t.Errorf("Payload = %q, want %q", got, tt.payload)
+			}
+			if p.IsFragment() != tt.fragment {
+				t.Errorf("IsFragment = %v, want %v", p.IsFragment(), tt.fragment)
+			}
+			if !p.ChecksumsValid() {
+				t.Error("captured checksums do not verify")
+			}
+		})
+	}
+}
+
+func TestDecodeTCPFields(t *testing.T) {
+	p, err := Decode(frame(t, "ipv4TCPSyn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TCP.Flags != TCPSyn || p.TCP.Seq != 1000 || p.TCP.Window != 64240 {
+		t.Errorf("TCP = %+v", p.TCP)
+	}
+	if got := hex.EncodeToString(p.TCP.Options); got != "020405b401030307" {
+		t.Errorf("TCP options = %s", got)
+	}
+	if p.IPv4.TTL != 64 || p.IPv4.Src != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("IPv4 = %+v", p.IPv4)
+	}
+}
+
+// TestRoundTrip re-encodes every frame unchanged, then clears and recomputes its
+// checksums; both must reproduce the captured bytes.
+func TestRoundTrip(t *testing.T) {
+	for name := range testFrames {
+		t.Run(name, func(t *testing.T) {
+			want := frame(t, name)
+			data := bytes.Clone(want)
+			p, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Encode(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, want) {
+				t.Fatalf("Encode changed the frame:\n got %x\nwant %x", data, want)
+			}
+
+			if p.IPv4 != nil {
+				p.IPv4.Checksum = 0
+			}
+			fragment := p.IsFragment()
+			if !fragment {
+				switch {
+				case p.TCP != nil:
+					p.TCP.Checksum = 0
+				case p.UDP != nil:
+					p.UDP.Checksum = 0
+				case p.ICMP != nil:
+					p.ICMP.Checksum = 0
+				}
+			}
+			if err := p.Encode(); err != nil {
+				t.Fatal(err)
+			}
+			p.RecomputeChecksums()
+			if !bytes.Equal(data, want) {
+				t.Fatalf("RecomputeChecksums did not restore the captured checksums:\n got %x\nwant %x", data, want)
+			}
+		})
+	}
+}
+
+func TestModifyAndRecompute(t *testing.T) {
+	for name := range testFrames {
+		t.Run(name, func(t *testing.T) {
+			data := frame(t, name)
+			p, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.IPv4 != nil {
+				p.IPv4.TTL--
+			} else {
+				p.IPv6.HopLimit--
+			}
+			if p.TCP != nil {
+				p.TCP.Seq++
+			}
+			if err := p.Encode(); err != nil {
+				t.Fatal(err)
+			}
+			if p.IPv4 != nil && p.ChecksumsValid() {
+				t.Fatal("checksums still verify after changing the TTL")
+			}
+			p.RecomputeChecksums()
+			if !p.ChecksumsValid() {
+				t.Fatal("checksums do not verify after RecomputeChecksums")
+			}
+
+			again, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.FiveTuple() != p.FiveTuple() || !again.ChecksumsValid() {
+				t.Errorf("re-decoded packet differs: %v", again.FiveTuple())
+			}
+		})
+	}
+}
+
+func TestRecomputeLeavesFragmentTransportChecksum(t *testing.T) {
+	for _, name := range []string{"ipv4FirstFragment", "ipv6FirstFragment"} {
+		t.Run(name, func(t *testing.T) {
+			p, err := Decode(frame(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := p.UDP.Checksum
+			p.Payload()[0] ^= 0xff
+			p.RecomputeChecksums()
+			if p.UDP.Checksum != want {
+				t.Errorf("fragment UDP checksum = %#04x, want it left at %#04x", p.UDP.Checksum, want)
+			}
+		})
+	}
+}
+
+func TestChecksumsValidDetectsCorruption(t *testing.T) {
+	for name := range testFrames {
+		t.Run(name, func(t *testing.T) {
+			data := frame(t, name)
+			p, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.IsFragment() {
+				t.Skip("fragment payloads are not covered by a checkable checksum")
+			}
+			data[p.End-1] ^= 0x01
+			if p.ChecksumsValid() {
+				t.Error("checksums verify after a bit flip")
+			}
+		})
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	for name := range testFrames {
+		t.Run(name, func(t *testing.T) {
+			data := frame(t, name)
+			for n := 0; n < len(data); n++ {
+				if _, err := Decode(data[:n]); !errors.Is(err, ErrTruncated) {
+					t.Fatalf("Decode of %d/%d bytes: err = %v, want ErrTruncated", n, len(data), err)
+				}
+			}
+		})
+	}
+}
+
+// TestDecodeTruncatedHeader shrinks the IP length so it ends inside the transport
+// header, which must be reported rather than read past.
+func TestDecodeTruncatedHeader(t *testing.T) {
+	v4 := frame(t, "ipv4TCPSyn")
+	v4[3] = 30 // total length: IPv4 header plus 10 of the 28 TCP header bytes
+	if _, err := Decode(v4); !errors.Is(err, ErrTruncated) {
+		t.Errorf("IPv4 with a cut TCP header: err = %v, want ErrTruncated", err)
+	}
+
+	v6 := frame(t, "ipv6UDP")
+	v6[5] = 4 // payload length: half a UDP header
+	if _, err := Decode(v6); !errors.Is(err, ErrTruncated) {
+		t.Errorf("IPv6 with a cut UDP header: err = %v, want ErrTruncated", err)
+	}
+
+	ext := frame(t, "ipv6HopByHopUDP")
+	ext[5] = 4 // payload length ends inside the hop-by-hop header
+	p, err := Decode(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TransportOffset != -1 || p.UDP != nil {
+		t.Errorf("cut extension chain: TransportOffset = %d, UDP = %v", p.TransportOffset, p.UDP)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := map[string]func([]byte){
+		"version":      func(b []byte) { b[0] = 0x55 },
+		"ihl":          func(b []byte) { b[0] = 0x44 },
+		"total length": func(b []byte) { b[2], b[3] = 0, 16 },
+		"data offset":  func(b []byte) { b[32] = 0x40 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := frame(t, "ipv4TCPSyn")
+			mutate(data)
+			if _, err := Decode(data); !errors.Is(err, ErrMalformed) {
+				t.Errorf("err = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	// RFC 1071 example header.
+	hdr, _ := hex.DecodeString("45000073000040004011b861c0a80001c0a800c7")
+	if got := Checksum(hdr); got != 0 {
+		t.Errorf("Checksum of a valid header = %#04x, want 0", got)
+	}
+	hdr[10], hdr[11] = 0, 0
+	if got := Checksum(hdr); got != 0xb861 {
+		t.Errorf("Checksum = %#04x, want 0xb861", got)
+	}
+	if got := Checksum([]byte{0x01}); got != 0xfeff {
+		t.Errorf("Checksum of an odd-length buffer = %#04x, want 0xfeff", got)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for name := range testFrames {
+		f.Add(frame(f, name))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		orig := bytes.Clone(data)
+		p, err := Decode(data)
+		if err != nil {
+			if !errors.Is(err, ErrTruncated) && !errors.Is(err, ErrMalformed) {
+				t.Fatalf("unexpected error type: %v", err)
+			}
+			return
+		}
+		if p.End > len(data) || p.TransportOffset > p.End || p.PayloadOffset > p.End {
+			t.Fatalf("offsets out of range: transport %d payload %d end %d len %d", p.TransportOffset, p.PayloadOffset, p.End, len(data))
+		}
+		_ = p.FiveTuple().String()
+		_ = p.Payload()
+		_ = p.ChecksumsValid()
+
+		if err := p.Encode(); err != nil {
+			t.Fatalf("Encode of a decoded packet: %v", err)
+		}
+		if !bytes.Equal(data, orig) {
+			t.Fatalf("Encode changed the packet:\n got %x\nwant %x", data, orig)
+		}
+
+		p.RecomputeChecksums()
+		if !p.ChecksumsValid() {
+			t.Fatal("checksums do not verify after RecomputeChecksums")
+		}
+		again, err := Decode(data)
+		if err != nil {
+			t.Fatalf("re-decode after RecomputeChecksums: %v", err)
+		}
+		if again.FiveTuple() != p.FiveTuple() || again.TransportOffset != p.TransportOffset {
+			t.Fatalf("re-decoded packet differs")
+		}
+	})
+}
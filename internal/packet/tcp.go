@@ -0,0 +1,105 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// TCPHeaderLen is the length of a TCP header without options.
+const TCPHeaderLen = 20
+
+// TCPFlags holds the TCP control bits.
+type TCPFlags uint8
+
+// TCP control bits.
+const (
+	TCPFin TCPFlags = 1 << iota
+	TCPSyn
+	TCPRst
+	TCPPsh
+	TCPAck
+	TCPUrg
+	TCPEce
+	TCPCwr
+)
+
+var tcpFlagNames = []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG", "ECE", "CWR"}
+
+// String lists the set flags, e.g. "SYN|ACK".
+func (f TCPFlags) String() string {
+	var names []string
+	for i, n := range tcpFlagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, n)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// TCP is a decoded TCP header.
+type TCP struct {
+	SrcPort, DstPort uint16
+	Seq, Ack         uint32
+	DataOffset       uint8 // header length in 32-bit words
+	Flags            TCPFlags
+	Window           uint16
+	Checksum         uint16
+	Urgent           uint16
+	Options          []byte
+}
+
+// HeaderLen returns the header length in bytes.
+func (h *TCP) HeaderLen() int {
+	return int(h.DataOffset) * 4
+}
+
+// Decode parses a TCP header from the start of b.
+func (h *TCP) Decode(b []byte) error {
+	if len(b) < TCPHeaderLen {
+		return fmt.Errorf("%w: TCP header needs %d bytes, have %d", ErrTruncated, TCPHeaderLen, len(b))
+	}
+	h.SrcPort = binary.BigEndian.Uint16(b)
+	h.DstPort = binary.BigEndian.Uint16(b[2:])
+	h.Seq = binary.BigEndian.Uint32(b[4:])
+	h.Ack = binary.BigEndian.Uint32(b[8:])
+	h.DataOffset = b[12] >> 4
+	hl := h.HeaderLen()
+	if hl < TCPHeaderLen {
+		return fmt.Errorf("%w: TCP data offset %d", ErrMalformed, h.DataOffset)
+	}
+	if len(b) < hl {
+		return fmt.Errorf("%w: TCP header with options needs %d bytes, have %d", ErrTruncated, hl, len(b))
+	}
+	h.Flags = TCPFlags(b[13])
+	h.Window = binary.BigEndian.Uint16(b[14:])
+	h.Checksum = binary.BigEndian.Uint16(b[16:])
+	h.Urgent = binary.BigEndian.Uint16(b[18:])
+	h.Options = b[TCPHeaderLen:hl]
+	return nil
+}
+
+// Encode writes the header into the start of b, which must hold HeaderLen bytes.
+func (h *TCP) Encode(b []byte) error {
+	hl := TCPHeaderLen + len(h.Options)
+	if hl%4 != 0 || hl > 60 {
+		return fmt.Errorf("%w: TCP options must pad the header to a multiple of 4 up to 60 bytes", ErrMalformed)
+	}
+	if len(b) < hl {
+		return fmt.Errorf("%w: need %d bytes to encode TCP header", ErrTruncated, hl)
+	}
+	h.DataOffset = uint8(hl / 4)
+	binary.BigEndian.PutUint16(b, h.SrcPort)
+	binary.BigEndian.PutUint16(b[2:], h.DstPort)
+	binary.BigEndian.PutUint32(b[4:], h.Seq)
+	binary.BigEndian.PutUint32(b[8:], h.Ack)
+	// The low nibble holds the reserved bits and the AccECN AE flag, which are not
+	// decoded; keep whatever b already has there.
+	b[12] = h.DataOffset<<4 | b[12]&0x0f
+	b[13] = byte(h.Flags)
+	binary.BigEndian.PutUint16(b[14:], h.Window)
+	binary.BigEndian.PutUint16(b[16:], h.Checksum)
+	binary.BigEndian.PutUint16(b[18:], h.Urgent)
+	copy(b[TCPHeaderLen:hl], h.Options)
+	return nil
+}
@@ -0,0 +1,40 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// UDPHeaderLen is the length of a UDP header.
+const UDPHeaderLen = 8
+
+// UDP is a decoded UDP header.
+type UDP struct {
+	SrcPort, DstPort uint16
+	Length           uint16 // header plus payload
+	Checksum         uint16
+}
+
+// Decode parses a UDP header from the start of b.
+func (h *UDP) Decode(b []byte) error {
+	if len(b) < UDPHeaderLen {
+		return fmt.Errorf("%w: UDP header needs %d bytes, have %d", ErrTruncated, UDPHeaderLen, len(b))
+	}
+	h.SrcPort = binary.BigEndian.Uint16(b)
+	h.DstPort = binary.BigEndian.Uint16(b[2:])
+	h.Length = binary.BigEndian.Uint16(b[4:])
+	h.Checksum = binary.BigEndian.Uint16(b[6:])
+	return nil
+}
+
+// Encode writes the header into the start of b.
+func (h *UDP) Encode(b []byte) error {
+	if len(b) < UDPHeaderLen {
+		return fmt.Errorf("%w: need %d bytes to encode UDP header", ErrTruncated, UDPHeaderLen)
+	}
+	binary.BigEndian.PutUint16(b, h.SrcPort)
+	binary.BigEndian.PutUint16(b[2:], h.DstPort)
+	binary.BigEndian.PutUint16(b[4:], h.Length)
+	binary.BigEndian.PutUint16(b[6:], h.Checksum)
+	return nil
+}
@@ -5,6 +5,18 @@ import (
 	"time"
 )
 
+// netTargetFlags are the ChaosBlade-style traffic selectors shared by net actions; they
+// are compiled into a WinDivert filter and ANDed with --filter when both are given.
+var netTargetFlags = []FlagSpec{
+	{Name: "remote-port", Type: "string", Default: "", Usage: "Remote ports to affect, comma-separated, ranges allowed (e.g. 443,8000-8080)"},
+	{Name: "local-port", Type: "string", Default: "", Usage: "Local ports to affect, comma-separated, ranges allowed"},
+	{Name: "destination-ip", Type: "string", Default: "", Usage: "Remote IPs or CIDRs to affect, comma-separated (e.g. 10.0.0.0/8)"},
+	{Name: "exclude-port", Type: "string", Default: "", Usage: "Ports to leave alone on either side, comma-separated, ranges allowed"},
+	{Name: "exclude-ip", Type: "string", Default: "", Usage: "IPs or CIDRs to leave alone on either side, comma-separated"},
+	{Name: "protocol", Type: "string", Default: "", Usage: "Protocol to affect: tcp, udp, icmp or all"},
+	{Name: "direction", Type: "string", Default: "", Usage: "Direction to affect: outbound (default with selectors), inbound or both"},
+}
+
 // Registry holds built-in target/action specifications used by the CLI.
 var Registry = map[string]TargetSpec{
 	"cpu": {
@@ -32,10 +44,20 @@ var Registry = map[string]TargetSpec{
 				Target: "mem",
 				Name:   "load",
 				Short:  "Allocate and hold memory",
-				Long:   "Allocates memory by size or percent of total and holds it until stopped.",
+				Long:   "Allocates memory by size or percent of total and holds it until stopped. Mode leak grows the allocation at --rate up to --max to mimic a leaking service; mode ramp reaches the target over --ramp-duration. Modes cache and mapped squeeze the file cache with a temporary backing file that is removed on stop. --target-used keeps the whole host at a usage percent instead.",
 				Flags: []FlagSpec{
 					{Name: "size", Type: "int64", Default: int64(256), Usage: "Memory to allocate in MB"},
 					{Name: "percent", Type: "float", Default: float64(0), Usage: "Memory to allocate as percent of total (overrides size if >0)"},
+					{Name: "mode", Type: "string", Default: "hold", Usage: "Allocation mode: hold, leak, ramp, cache (page cache via temp file), or mapped (memory-mapped temp file)"},
+					{Name: "rate", Type: "string", Default: "", Usage: "Growth rate for leak mode (e.g. 10MB/s)"},
+					{Name: "max", Type: "string", Default: "", Usage: "Upper bound for leak mode (e.g. 2GB; defaults to size/percent)"},
+					{Name: "ramp-duration", Type: "duration", Default: time.Duration(0), Usage: "Time to reach the target in ramp mode (e.g. 5m)"},
+					{Name: "resident", Type: "bool", Default: false, Usage: "Allocate outside the Go heap and re-touch all pages so the memory stays resident"},
+					{Name: "lock", Type: "bool", Default: false, Usage: "Pin resident memory in RAM (mlock/VirtualLock); implies --resident"},
+					{Name: "min-free", Type: "string", Default: "256MB", Usage: "Free-memory floor the experiment never allocates into (0 disables)"},
+					{Name: "critical-free", Type: "string", Default: "128MB", Usage: "Release all memory and stop if available memory falls below this (0 disables)"},
+					{Name: "clamp", Type: "bool", Default: false, Usage: "Shrink a request that would breach --min-free instead of refusing it"},
+					{Name: "target-used", Type: "float", Default: float64(0), Usage: "Hold system-wide memory usage at this percent, growing or shrinking as other processes change (overrides size/percent/mode if >0)"},
 				},
 			},
 		},
@@ -48,11 +70,141 @@ var Registry = map[string]TargetSpec{
 				Target: "disk",
 				Name:   "fill",
 				Short:  "Fill disk with temporary data",
-				Long:   "Writes data to a file until the requested size/percent is reached, then holds it.",
+				Long:   "Writes data to a file until the requested size/percent is reached, then holds it. --free-left and --used-percent instead fill until the volume reaches a free-space target and keep topping up while holding. Several volumes can be filled concurrently under one experiment id with repeated --path or --volumes.",
 				Flags: []FlagSpec{
 					{Name: "size", Type: "int64", Default: int64(512), Usage: "Data size to write in MB"},
 					{Name: "percent", Type: "float", Default: float64(0), Usage: "Data to write as percent of disk total (overrides size if >0)"},
-					{Name: "path", Type: "string", Default: "", Usage: "Target file path (defaults to temp file)"},
+					{Name: "free-left", Type: "string", Default: "", Usage: "Fill until the volume has this much free space (e.g. 500MB; overrides size/percent)"},
+					{Name: "used-percent", Type: "float", Default: float64(0), Usage: "Fill until the volume is this percent used (overrides size/percent if >0)"},
+					{Name: "method", Type: "string", Default: "write", Usage: "How space is claimed: write (data, with progress), fallocate (fast preallocation), or truncate (extend length; rejected if the result is sparse)"},
+					{Name: "content", Type: "string", Default: "zero", Usage: "Data written by the write method: zero, random (defeats compression), or incompressible (defeats compression and dedup)"},
+					{Name: "rate", Type: "string", Default: "", Usage: "Limit the write method to this throughput (e.g. 50MB/s) so the file grows steadily"},
+					{Name: "path", Type: "stringArray", Default: []string(nil), Usage: "Target file or directory, repeatable for several volumes; append =SIZE or =N% to override size/percent for that path (defaults to temp file)"},
+					{Name: "volumes", Type: "string", Default: "", Usage: "Comma-separated volumes to fill concurrently, each optionally with =SIZE or =N% (e.g. C:,D:=20%)"},
+				},
+			},
+			"burn": {
+				Target: "disk",
+				Name:   "burn",
+				Short:  "Generate sustained disk read/write IO",
+				Long:   "Drives random or sequential reads and/or writes against a working file on the target volume with a configurable queue depth, using unbuffered IO where supported, and reports achieved IOPS and throughput.",
+				Flags: []FlagSpec{
+					{Name: "read", Type: "bool", Default: false, Usage: "Issue read IO"},
+					{Name: "write", Type: "bool", Default: false, Usage: "Issue write IO"},
+					{Name: "read-percent", Type: "int", Default: 50, Usage: "Share of reads when both --read and --write are set (0-100)"},
+					{Name: "block-size", Type: "string", Default: "4k", Usage: "IO block size (e.g. 4k, 64k, 1MB)"},
+					{Name: "iodepth", Type: "int", Default: 8, Usage: "Number of concurrent outstanding IOs"},
+					{Name: "pattern", Type: "string", Default: "random", Usage: "Access pattern: random or sequential"},
+					{Name: "path", Type: "string", Default: "", Usage: "Directory or volume for the working file (defaults to temp dir)"},
+					{Name: "file-size", Type: "string", Default: "1GB", Usage: "Size of the working file"},
+					{Name: "direct", Type: "bool", Default: true, Usage: "Bypass the OS cache (unbuffered IO) where supported"},
+				},
+			},
+			"delay": {
+				Target: "disk",
+				Name:   "delay",
+				Short:  "Add IO latency to one directory",
+				Long:   "Mounts a passthrough filesystem over the directory in place and delays reads and writes under it, leaving other paths on the volume untouched. The mount is removed on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "Directory whose IO is delayed (required)"},
+					{Name: "read-latency", Type: "duration", Default: time.Duration(0), Usage: "Delay added to each read (e.g. 50ms)"},
+					{Name: "write-latency", Type: "duration", Default: time.Duration(0), Usage: "Delay added to each write and fsync (e.g. 200ms)"},
+					{Name: "percent", Type: "float", Default: float64(100), Usage: "Chance (0-100) that an operation is delayed"},
+					{Name: "backend", Type: "string", Default: "", Usage: "Interposition backend (defaults to the first available, e.g. fuse)"},
+				},
+			},
+			"files": {
+				Target: "disk",
+				Name:   "files",
+				Short:  "Create many small files to exhaust inodes/MFT records",
+				Long:   "Creates the requested number of small files (optionally nested into subdirectories) under a tracked directory, holds them, and removes the whole tree on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "count", Type: "int64", Default: int64(100000), Usage: "Number of files to create"},
+					{Name: "dir", Type: "string", Default: "", Usage: "Parent directory for the tracked file tree (defaults to temp dir)"},
+					{Name: "size", Type: "string", Default: "0", Usage: "Size of each file (e.g. 0, 512, 4k)"},
+					{Name: "per-dir", Type: "int64", Default: int64(0), Usage: "Maximum entries per directory; files are nested into subdirectories when >0"},
+				},
+			},
+		},
+	},
+	"file": {
+		Name:  "file",
+		Short: "File and directory experiments",
+		Actions: map[string]ActionSpec{
+			"append": {
+				Target: "file",
+				Name:   "append",
+				Short:  "Append random garbage to a file",
+				Long:   "Backs up the file into the experiment state directory, appends random bytes, and restores the original on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "File to tamper with (required)"},
+					{Name: "size", Type: "string", Default: "4k", Usage: "Amount of garbage to append (e.g. 512, 4k, 1MB)"},
+				},
+			},
+			"chmod": {
+				Target: "file",
+				Name:   "chmod",
+				Short:  "Change the permissions of a file",
+				Long:   "Backs up the file, applies --mode, and restores the original permissions on stop or destroy. On Windows only the read-only attribute is affected (a mode without owner write sets it).",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "File to tamper with (required)"},
+					{Name: "mode", Type: "string", Default: "000", Usage: "Octal permissions to apply (e.g. 000, 444)"},
+				},
+			},
+			"flip": {
+				Target: "file",
+				Name:   "flip",
+				Short:  "Flip random bytes in a file",
+				Long:   "Backs up the file, inverts bits in randomly chosen bytes to simulate silent corruption, and restores the original on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "File to tamper with (required)"},
+					{Name: "count", Type: "int", Default: 16, Usage: "Number of bytes to corrupt"},
+				},
+			},
+			"lock": {
+				Target: "file",
+				Name:   "lock",
+				Short:  "Hold an exclusive lock on a file",
+				Long:   "Backs up the file and holds an exclusive lock on it until stop or destroy. On Windows the file is opened without sharing so other processes cannot open it; on Unix the lock is an advisory flock.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "File to tamper with (required)"},
+				},
+			},
+			"rename": {
+				Target: "file",
+				Name:   "rename",
+				Short:  "Rename a file away",
+				Long:   "Backs up the file and moves it aside so the original path is missing; the original is restored and the moved copy removed on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "File to tamper with (required)"},
+					{Name: "to", Type: "string", Default: "", Usage: "Destination for the moved file (defaults to <path>.chaosblade)"},
+				},
+			},
+			"truncate": {
+				Target: "file",
+				Name:   "truncate",
+				Short:  "Truncate a file",
+				Long:   "Backs up the file, truncates it to --size, and restores the original on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "File to tamper with (required)"},
+					{Name: "size", Type: "string", Default: "0", Usage: "Resulting file length (e.g. 0, 100, 4k)"},
+				},
+			},
+			"fault": {
+				Target: "file",
+				Name:   "fault",
+				Short:  "Inject errors, latency or short reads into a directory",
+				Long:   "Mounts a passthrough filesystem over the directory in place and injects errors, latency or short reads into the selected operations on paths matching --glob. Applications keep using their configured paths; the mount is removed on stop or destroy.",
+				Flags: []FlagSpec{
+					{Name: "path", Type: "string", Default: "", Usage: "Directory to interpose on (required)"},
+					{Name: "ops", Type: "string", Default: "read,write", Usage: "Comma-separated operations: open, create, read, write, fsync, truncate, stat, readdir, mkdir, rmdir, unlink, rename, or all"},
+					{Name: "glob", Type: "string", Default: "", Usage: "Comma-separated path globs relative to --path (e.g. '*.db,wal/*'); empty matches everything"},
+					{Name: "error", Type: "string", Default: "", Usage: "Error returned by affected operations (EIO, ENOSPC, EROFS, EACCES, EPERM, ENOENT, EAGAIN, EBUSY)"},
+					{Name: "latency", Type: "duration", Default: time.Duration(0), Usage: "Delay added to affected operations (e.g. 200ms)"},
+					{Name: "short-read", Type: "int", Default: 0, Usage: "Return at most this many bytes per affected read (0 disables)"},
+					{Name: "percent", Type: "float", Default: float64(100), Usage: "Chance (0-100) that a matching operation is affected"},
+					{Name: "read-only", Type: "bool", Default: false, Usage: "Fail every mutating operation with EROFS"},
+					{Name: "backend", Type: "string", Default: "", Usage: "Interposition backend (defaults to the first available, e.g. fuse)"},
 				},
 			},
 		},
@@ -66,13 +218,46 @@ var Registry = map[string]TargetSpec{
 				Name:   "delay",
 				Short:  "Inject network delay/loss/bandwidth (WinDivert)",
 				Long:   "Shapes traffic with delay, jitter, packet loss, and bandwidth caps using WinDivert.",
-				Flags: []FlagSpec{
+				Flags: append([]FlagSpec{
 					{Name: "delay", Type: "int", Default: 100, Usage: "Base one-way delay in ms"},
-					{Name: "jitter", Type: "int", Default: 0, Usage: "Jitter in ms"},
-					{Name: "loss", Type: "float", Default: 0, Usage: "Packet loss percent (0-100)"},
-					{Name: "bandwidth", Type: "int", Default: 0, Usage: "Bandwidth cap in kbps (0 means unlimited)"},
-					{Name: "filter", Type: "string", Default: "outbound and tcp", Usage: "WinDivert filter expression (e.g., 'outbound and tcp')"},
-				},
+					{Name: "jitter", Type: "int", Default: 0, Usage: "Jitter in ms: the half-width for uniform, the standard deviation for other distributions"},
+					{Name: "distribution", Type: "string", Default: "uniform", Usage: "Delay distribution: uniform, normal, pareto or paretonormal"},
+					{Name: "distribution-file", Type: "string", Default: "", Usage: "Custom delay distribution table in netem .dist format (values scaled by 8192); overrides --distribution"},
+					{Name: "delay-correlation", Type: "float", Default: float64(0), Usage: "Percent correlation between successive packet delays (0-100)"},
+					{Name: "loss", Type: "float", Default: 0, Usage: "Packet loss percent (0-100); the average rate for the bernoulli and correlated loss models"},
+					{Name: "loss-model", Type: "string", Default: "bernoulli", Usage: "Loss model: bernoulli (independent drops), correlated (drops cluster per --loss-correlation) or gilbert-elliott (bursts per --ge-*)"},
+					{Name: "loss-correlation", Type: "float", Default: float64(0), Usage: "Correlated loss: percent correlation between successive drop decisions (0-100)"},
+					{Name: "ge-p", Type: "float", Default: float64(0), Usage: "Gilbert-Elliott: percent chance per packet of entering the bad state"},
+					{Name: "ge-r", Type: "float", Default: float64(0), Usage: "Gilbert-Elliott: percent chance per packet of leaving the bad state (mean burst is 100/ge-r packets)"},
+					{Name: "ge-bad-loss", Type: "float", Default: float64(100), Usage: "Gilbert-Elliott: loss percent in the bad state"},
+					{Name: "ge-good-loss", Type: "float", Default: float64(0), Usage: "Gilbert-Elliott: loss percent in the good state"},
+					{Name: "bandwidth", Type: "int", Default: 0, Usage: "Bandwidth cap in kbps per direction, shaped by a token bucket with a bounded queue (0 means unlimited)"},
+					{Name: "filter", Type: "string", Default: "outbound and tcp", Usage: "WinDivert filter expression (e.g., 'outbound and tcp'); ANDed with the selector flags when they are set"},
+					{Name: "burst", Type: "string", Default: "", Usage: "Bandwidth shaper bucket size (e.g. 32KB; defaults to 10ms of traffic)"},
+					{Name: "queue-limit", Type: "string", Default: "1000", Usage: "Bandwidth shaper queue limit per direction: a packet count (e.g. 100) or a size (e.g. 64KB)"},
+					{Name: "queue-policy", Type: "string", Default: "tail-drop", Usage: "Drop policy when the shaper queue fills: tail-drop or red"},
+					{Name: "max-queued", Type: "int", Default: 10000, Usage: "Maximum packets held for delay; packets arriving while full are dropped and counted"},
+					{Name: "duplicate", Type: "float", Default: float64(0), Usage: "Percent of packets to send twice (0-100)"},
+					{Name: "reorder", Type: "float", Default: float64(0), Usage: "Percent of packets to hold back behind later packets (0-100)"},
+					{Name: "reorder-gap", Type: "int", Default: 5, Usage: "How many later packets overtake a reordered one"},
+					{Name: "seed", Type: "int64", Default: int64(0), Usage: "Random seed for loss, corruption, duplication, reordering and jitter (0 picks one from the clock)"},
+					{Name: "replay", Type: "string", Default: "", Usage: "Replay packets from a pcap file instead of intercepting live traffic (offline runs; no WinDivert needed)"},
+					{Name: "capture", Type: "string", Default: "", Usage: "With --replay, write the shaped packets to this pcap file"},
+				}, netTargetFlags...),
+			},
+			"corrupt": {
+				Target: "net",
+				Name:   "corrupt",
+				Short:  "Flip bits in matching packets (WinDivert)",
+				Long:   "Flips one random bit in a percentage of matching packets. The payload and header modes leave checksums stale so the stack drops the packet and TCP retransmits; checksum-valid recomputes them so the corruption reaches the application.",
+				Flags: append([]FlagSpec{
+					{Name: "percent", Type: "float", Default: float64(1), Usage: "Percent of matching packets to corrupt (0-100]"},
+					{Name: "mode", Type: "string", Default: "payload", Usage: "What to corrupt: payload, header or checksum-valid"},
+					{Name: "seed", Type: "int64", Default: int64(0), Usage: "Random seed for choosing packets and bits (0 picks one from the clock)"},
+					{Name: "filter", Type: "string", Default: "outbound and tcp", Usage: "WinDivert filter expression (e.g., 'outbound and tcp'); ANDed with the selector flags when they are set"},
+					{Name: "replay", Type: "string", Default: "", Usage: "Replay packets from a pcap file instead of intercepting live traffic (offline runs; no WinDivert needed)"},
+					{Name: "capture", Type: "string", Default: "", Usage: "With --replay, write the corrupted packets to this pcap file"},
+				}, netTargetFlags...),
 			},
 		},
 	},